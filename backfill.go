@@ -0,0 +1,148 @@
+package gobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	// seekByTimeMaxRecords is the number of records the seek_by_time endpoints return from one time point.
+	seekByTimeMaxRecords = 10000
+	seekByTimeLimit      = 50
+)
+
+// ErrBackfillSecondOverflow is wrapped by BackfillSecondOverflowError.
+var ErrBackfillSecondOverflow = errors.New("birdeye: backfill second overflow")
+
+// BackfillSecondOverflowError is returned by BackfillTrades and BackfillPairTrades when more trades
+// share one BlockUnixTime than a seek_by_time query can reach, the trades of that second beyond
+// the cap can not be fetched. The trades before, and the reachable trades of UnixTime, were passed to fn,
+// resume from UnixTime+1 to skip the second.
+// It wraps ErrBackfillSecondOverflow, so both errors.Is and errors.As work.
+type BackfillSecondOverflowError struct {
+	// UnixTime is the BlockUnixTime of the overflowing second
+	UnixTime int64
+}
+
+func (e *BackfillSecondOverflowError) Error() string {
+	return fmt.Sprintf("%s at unix time %d, trades beyond the cap are not reachable", ErrBackfillSecondOverflow, e.UnixTime)
+}
+
+func (e *BackfillSecondOverflowError) Unwrap() error {
+	return ErrBackfillSecondOverflow
+}
+
+// BackfillTrades streams every trade of a token with from <= BlockUnixTime <= to in time order.
+//
+// It walks TradeByTokenAndTime with afterTime, and re-anchors afterTime on the latest
+// seen BlockUnixTime when the 10,000-record cap of one time point is reached.
+// Trades sharing a BlockUnixTime across the re-anchoring boundary are de-duplicated by TxHash.
+// Returning an error from fn stops the backfill and returns that error.
+// If more trades than the cap share one BlockUnixTime, it stops with a *BackfillSecondOverflowError.
+//
+// Parameters:
+//   - chain: The blockchain network
+//   - address: The token address
+//   - txType: Type of transactions to filter by ("swap", "add", "remove", "all", default: "swap")
+//   - from: Unix timestamp in seconds of the first trade, must be > 0
+//   - to: Unix timestamp in seconds of the last trade
+//   - fn: Called for every trade in time order
+func (c *Client) BackfillTrades(ctx context.Context, chain string, address string, txType TxType, from, to int64, fn func(RespTradesByTokenItem) error) error {
+	fetch := func(ctx context.Context, afterTime int64, offset, limit int) (RespItems[RespTradesByTokenItem], error) {
+		return c.TradeByTokenAndTimeCtx(ctx, chain, address, 0, afterTime, txType, offset, limit)
+	}
+	key := func(item RespTradesByTokenItem) (string, int64) {
+		return item.TxHash, item.BlockUnixTime
+	}
+	return backfillTrades(ctx, fetch, seekByTimeMaxRecords-seekByTimeLimit, from, to, key, fn)
+}
+
+// BackfillPairTrades is like BackfillTrades but for a pair address, using TradesByPairAndTime.
+// TradesByPairAndTime accepts offsets up to 1000, so it re-anchors more often.
+func (c *Client) BackfillPairTrades(ctx context.Context, chain string, address string, txType TxType, from, to int64, fn func(RespTradesByPairItem) error) error {
+	fetch := func(ctx context.Context, afterTime int64, offset, limit int) (RespItems[RespTradesByPairItem], error) {
+		return c.TradesByPairAndTimeCtx(ctx, chain, address, 0, afterTime, txType, offset, limit)
+	}
+	key := func(item RespTradesByPairItem) (string, int64) {
+		return item.TxHash, item.BlockUnixTime
+	}
+	return backfillTrades(ctx, fetch, 1000, from, to, key, fn)
+}
+
+type seekByTimeFetcher[T any] func(ctx context.Context, afterTime int64, offset, limit int) (RespItems[T], error)
+
+func backfillTrades[T any](ctx context.Context, fetch seekByTimeFetcher[T], maxOffset int, from, to int64, key func(T) (string, int64), fn func(T) error) error {
+	if from <= 0 {
+		return fmt.Errorf("birdeye: backfill from must be > 0")
+	}
+	if to < from {
+		return fmt.Errorf("birdeye: backfill to %d is before from %d", to, from)
+	}
+	// afterTime is exclusive, start one second earlier to include trades at from
+	anchor := from - 1
+	// seen holds the hashes of emitted trades that may show up again after re-anchoring
+	seen := seenTrades{}
+	for {
+		last := anchor
+		offset := 0
+		for {
+			page, err := fetch(ctx, anchor, offset, seekByTimeLimit)
+			if err != nil {
+				return err
+			}
+			items := page.Items
+			sort.SliceStable(items, func(i, j int) bool {
+				_, ti := key(items[i])
+				_, tj := key(items[j])
+				return ti < tj
+			})
+			for _, item := range items {
+				hash, t := key(item)
+				if t > to {
+					return nil
+				}
+				last = max(last, t)
+				if t < from {
+					continue
+				}
+				if _, ok := seen[hash]; ok {
+					continue
+				}
+				seen[hash] = t
+				if err := fn(item); err != nil {
+					return err
+				}
+			}
+			offset += len(items)
+			if !page.HasNext || len(items) == 0 {
+				return nil
+			}
+			if offset > maxOffset {
+				break
+			}
+		}
+		// re-anchor one second before the latest trade, so trades sharing its
+		// BlockUnixTime but beyond the cap are fetched again and de-duplicated
+		next := last - 1
+		if next <= anchor {
+			// every trade after anchor shares the second last, more than the cap
+			return &BackfillSecondOverflowError{UnixTime: last}
+		}
+		anchor = next
+		seen.prune(anchor)
+	}
+}
+
+// seenTrades maps the hashes of emitted trades to their BlockUnixTime.
+type seenTrades map[string]int64
+
+// prune forgets the trades up to anchor, afterTime is exclusive so a query after anchor can not return them again.
+func (s seenTrades) prune(anchor int64) {
+	for hash, t := range s {
+		if t <= anchor {
+			delete(s, hash)
+		}
+	}
+}
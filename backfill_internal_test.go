@@ -0,0 +1,13 @@
+package gobe
+
+import "testing"
+
+func TestBackfillSeenPrune(t *testing.T) {
+	seen := seenTrades{"a": 9, "b": 10, "c": 11}
+	// after re-anchoring at 10 only trades after 10 are fetched again, afterTime is exclusive,
+	// so the trades of the anchor second are dropped and those of the next second kept
+	seen.prune(10)
+	if _, ok := seen["c"]; !ok || len(seen) != 1 {
+		t.Fatalf("expected only trades after the anchor kept, got %v", seen)
+	}
+}
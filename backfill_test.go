@@ -0,0 +1,181 @@
+package gobe_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/dwdwow/gobe"
+)
+
+// newSeekByTimeServer serves trades, sorted by time, like the seek_by_time endpoints with after_time,
+// rejecting offsets beyond maxOffset. onRequest, if not nil, is called with the after_time and offset of every request.
+func newSeekByTimeServer[T any](trades []T, unixTime func(T) int64, maxOffset int, onRequest func(afterTime int64, offset int)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		afterTime, _ := strconv.ParseInt(q.Get("after_time"), 10, 64)
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		if onRequest != nil {
+			onRequest(afterTime, offset)
+		}
+		if offset > maxOffset {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		after := trades[sort.Search(len(trades), func(i int) bool { return unixTime(trades[i]) > afterTime }):]
+		items := after[min(offset, len(after)):min(offset+limit, len(after))]
+		json.NewEncoder(w).Encode(gobe.RespData[gobe.RespItems[T]]{
+			Success: true,
+			Data:    gobe.RespItems[T]{Items: items, HasNext: offset+limit < len(after)},
+		})
+	}))
+}
+
+// checkBackfilled checks got holds n distinct trades in time order from first to last.
+func checkBackfilled(t *testing.T, hashes []string, times []int64, n int, first, last int64) {
+	t.Helper()
+	if len(hashes) != n {
+		t.Fatalf("expected %d trades, got %d", n, len(hashes))
+	}
+	seen := map[string]bool{}
+	for i, hash := range hashes {
+		if seen[hash] {
+			t.Fatalf("duplicated trade %s", hash)
+		}
+		seen[hash] = true
+		if i > 0 && times[i] < times[i-1] {
+			t.Fatalf("trade %s out of order", hash)
+		}
+	}
+	if times[0] != first || times[len(times)-1] != last {
+		t.Fatalf("unexpected range %d..%d", times[0], times[len(times)-1])
+	}
+}
+
+func TestBackfillPairTrades(t *testing.T) {
+	// 3 trades per second from unix time 1000 to 1999
+	var trades []gobe.RespTradesByPairItem
+	for ts := int64(1000); ts < 2000; ts++ {
+		for i := 0; i < 3; i++ {
+			trades = append(trades, gobe.RespTradesByPairItem{TxHash: fmt.Sprintf("%d-%d", ts, i), BlockUnixTime: ts})
+		}
+	}
+	// the 1000-offset cap of the pair endpoint is reached every 350 seconds
+	anchors := map[int64]bool{}
+	maxOffset := 0
+	srv := newSeekByTimeServer(trades, func(trade gobe.RespTradesByPairItem) int64 { return trade.BlockUnixTime }, 1000,
+		func(afterTime int64, offset int) {
+			anchors[afterTime] = true
+			maxOffset = max(maxOffset, offset)
+		})
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	var hashes []string
+	var times []int64
+	err := clt.BackfillPairTrades(context.Background(), gobe.CHAIN_SOLANA, "8sN9549P3Zn6xpQRqpApN57xzkCh6sJxLwuEjcG2W4Ji", gobe.TX_TYPE_ALL, 1100, 1899,
+		func(item gobe.RespTradesByPairItem) error {
+			hashes = append(hashes, item.TxHash)
+			times = append(times, item.BlockUnixTime)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBackfilled(t, hashes, times, 800*3, 1100, 1899)
+	if len(anchors) < 3 || maxOffset != 1000 {
+		t.Fatalf("expected re-anchoring at the 1000 offset cap, got %d anchors and max offset %d", len(anchors), maxOffset)
+	}
+}
+
+func TestBackfillTrades(t *testing.T) {
+	// 30 trades per second from unix time 1000 to 1999, the 10,000-record cap is reached every 333 seconds
+	var trades []gobe.RespTradesByTokenItem
+	for ts := int64(1000); ts < 2000; ts++ {
+		for i := 0; i < 30; i++ {
+			trades = append(trades, gobe.RespTradesByTokenItem{TxHash: fmt.Sprintf("%d-%d", ts, i), BlockUnixTime: ts})
+		}
+	}
+	srv := newSeekByTimeServer(trades, func(trade gobe.RespTradesByTokenItem) int64 { return trade.BlockUnixTime }, 10000, nil)
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	var hashes []string
+	var times []int64
+	err := clt.BackfillTrades(context.Background(), gobe.CHAIN_SOLANA, "HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC", gobe.TX_TYPE_ALL, 1000, 1999,
+		func(item gobe.RespTradesByTokenItem) error {
+			hashes = append(hashes, item.TxHash)
+			times = append(times, item.BlockUnixTime)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBackfilled(t, hashes, times, 1000*30, 1000, 1999)
+}
+
+func TestBackfillSecondOverflow(t *testing.T) {
+	// 2 trades per second from unix time 1000 to 1099, except 1500 trades at 1050
+	var trades []gobe.RespTradesByPairItem
+	for ts := int64(1000); ts < 1100; ts++ {
+		n := 2
+		if ts == 1050 {
+			n = 1500
+		}
+		for i := 0; i < n; i++ {
+			trades = append(trades, gobe.RespTradesByPairItem{TxHash: fmt.Sprintf("%d-%d", ts, i), BlockUnixTime: ts})
+		}
+	}
+	srv := newSeekByTimeServer(trades, func(trade gobe.RespTradesByPairItem) int64 { return trade.BlockUnixTime }, 1000, nil)
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	backfill := func(from int64) ([]gobe.RespTradesByPairItem, error) {
+		var got []gobe.RespTradesByPairItem
+		err := clt.BackfillPairTrades(context.Background(), gobe.CHAIN_SOLANA, "8sN9549P3Zn6xpQRqpApN57xzkCh6sJxLwuEjcG2W4Ji", gobe.TX_TYPE_ALL, from, 1099,
+			func(item gobe.RespTradesByPairItem) error {
+				got = append(got, item)
+				return nil
+			})
+		return got, err
+	}
+
+	got, err := backfill(1000)
+	var overflow *gobe.BackfillSecondOverflowError
+	if !errors.As(err, &overflow) || !errors.Is(err, gobe.ErrBackfillSecondOverflow) {
+		t.Fatalf("expected second overflow error, got %v", err)
+	}
+	if overflow.UnixTime != 1050 {
+		t.Fatalf("expected overflow at 1050, got %d", overflow.UnixTime)
+	}
+	var before, at int
+	for _, trade := range got {
+		switch {
+		case trade.BlockUnixTime < 1050:
+			before++
+		case trade.BlockUnixTime == 1050:
+			at++
+		default:
+			t.Fatalf("trade %s after the overflowing second", trade.TxHash)
+		}
+	}
+	if before != 50*2 || at == 0 || at >= 1500 {
+		t.Fatalf("expected %d trades before and some at the overflowing second, got %d and %d", 50*2, before, at)
+	}
+
+	// resuming after the overflowing second gets the rest
+	got, err = backfill(overflow.UnixTime + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 49*2 || got[0].BlockUnixTime != 1051 {
+		t.Fatalf("expected %d trades from 1051, got %d", 49*2, len(got))
+	}
+}
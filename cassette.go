@@ -0,0 +1,251 @@
+package gobe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CassetteMode is how a Cassette serves requests.
+type CassetteMode string
+
+const (
+	// CASSETTE_REPLAY serves requests from the cassette only, unmatched requests fail with ErrCassetteMiss
+	CASSETTE_REPLAY CassetteMode = "replay"
+	// CASSETTE_RECORD sends every request and records it, replacing the interactions of the same request
+	CASSETTE_RECORD CassetteMode = "record"
+	// CASSETTE_AUTO replays matched requests and sends and records the others
+	CASSETTE_AUTO CassetteMode = "auto"
+)
+
+// ErrCassetteMiss is returned for requests not found in a cassette in CASSETTE_REPLAY mode.
+var ErrCassetteMiss = errors.New("birdeye: request not found in cassette")
+
+// CassetteRequest is a recorded request, the api key is never recorded.
+type CassetteRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Query is the normalized query, see NormalizeQuery
+	Query string `json:"query,omitempty"`
+	Chain string `json:"chain,omitempty"`
+}
+
+// CassetteResponse is a recorded response.
+type CassetteResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	// Body is the response body, json bodies are kept as is for readable cassettes
+	Body json.RawMessage `json:"body,omitempty"`
+	// Text is the response body if it is not json
+	Text string `json:"text,omitempty"`
+}
+
+// CassetteInteraction is a request and its response.
+type CassetteInteraction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// Cassette is an http.RoundTripper recording requests to birdeye and their responses to a json file,
+// and replaying them offline. Use it with WithCassette.
+//
+// Requests match on method, path, normalized query and x-chain header.
+// Identical requests are replayed in the order they were recorded, the last one repeating.
+// The api key is scrubbed from recorded requests and responses.
+type Cassette struct {
+	path  string
+	mode  CassetteMode
+	inner http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []CassetteInteraction
+	// next is the index of the next interaction to replay per request key
+	next map[string]int
+	// recorded are the request keys recorded in CASSETTE_RECORD mode, their old interactions are dropped
+	recorded map[string]bool
+	changed  bool
+	misses   []CassetteRequest
+}
+
+// NewCassette loads the cassette file path, which may not exist unless mode is CASSETTE_REPLAY.
+// inner sends the requests to record, nil is http.DefaultTransport.
+func NewCassette(path string, mode CassetteMode, inner http.RoundTripper) (*Cassette, error) {
+	switch mode {
+	case CASSETTE_REPLAY, CASSETTE_RECORD, CASSETTE_AUTO:
+	default:
+		return nil, fmt.Errorf("birdeye: invalid cassette mode %q", mode)
+	}
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	c := &Cassette{path: path, mode: mode, inner: inner, next: map[string]int{}, recorded: map[string]bool{}}
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(b, &c.interactions); err != nil {
+			return nil, fmt.Errorf("birdeye: decode cassette %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && mode != CASSETTE_REPLAY:
+	default:
+		return nil, fmt.Errorf("birdeye: open cassette: %w", err)
+	}
+	return c, nil
+}
+
+// WithCassette sends the requests of the client through the cassette.
+func WithCassette(c *Cassette) ClientOption {
+	return WithTransport(c)
+}
+
+// NormalizeQuery returns the query with keys and the values of each key sorted.
+func NormalizeQuery(q url.Values) string {
+	sorted := make(url.Values, len(q))
+	for k, vs := range q {
+		// the api key is never sent in the query, but must never be recorded either
+		if strings.EqualFold(k, "x-api-key") {
+			continue
+		}
+		vs = append([]string(nil), vs...)
+		sort.Strings(vs)
+		sorted[k] = vs
+	}
+	return sorted.Encode()
+}
+
+func cassetteRequestOf(req *http.Request) CassetteRequest {
+	return CassetteRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  NormalizeQuery(req.URL.Query()),
+		Chain:  req.Header.Get("x-chain"),
+	}
+}
+
+func (r CassetteRequest) key() string {
+	return r.Method + " " + r.Path + "?" + r.Query + " " + r.Chain
+}
+
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	creq := cassetteRequestOf(req)
+	key := creq.key()
+	if c.mode != CASSETTE_RECORD {
+		if resp, ok := c.replay(req, key); ok {
+			return resp, nil
+		}
+		if c.mode == CASSETTE_REPLAY {
+			c.mu.Lock()
+			c.misses = append(c.misses, creq)
+			c.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s %s?%s, chain %q", ErrCassetteMiss, creq.Method, creq.Path, creq.Query, creq.Chain)
+		}
+	}
+	resp, err := c.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	secret := Secret(req.Header.Get("x-api-key"))
+	cresp := CassetteResponse{StatusCode: resp.StatusCode, Header: secret.RedactHeader(resp.Header)}
+	if scrubbed := secret.Redact(string(body)); json.Valid([]byte(scrubbed)) {
+		cresp.Body = json.RawMessage(scrubbed)
+	} else {
+		cresp.Text = scrubbed
+	}
+	creq.Query = secret.Redact(creq.Query)
+	c.mu.Lock()
+	if c.mode == CASSETTE_RECORD && !c.recorded[key] {
+		c.recorded[key] = true
+		kept := c.interactions[:0]
+		for _, it := range c.interactions {
+			if it.Request.key() != key {
+				kept = append(kept, it)
+			}
+		}
+		c.interactions = kept
+	}
+	c.interactions = append(c.interactions, CassetteInteraction{Request: creq, Response: cresp})
+	c.changed = true
+	c.mu.Unlock()
+	return newCassetteResponse(req, cresp), nil
+}
+
+// replay returns the next recorded response of key.
+func (c *Cassette) replay(req *http.Request, key string) (*http.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var matches []int
+	for i, it := range c.interactions {
+		if it.Request.key() == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+	n := min(c.next[key], len(matches)-1)
+	c.next[key] = n + 1
+	return newCassetteResponse(req, c.interactions[matches[n]].Response), true
+}
+
+func newCassetteResponse(req *http.Request, r CassetteResponse) *http.Response {
+	body := []byte(r.Body)
+	if r.Body == nil {
+		body = []byte(r.Text)
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Misses returns the requests not found in the cassette in CASSETTE_REPLAY mode.
+func (c *Cassette) Misses() []CassetteRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CassetteRequest(nil), c.misses...)
+}
+
+// Save writes the cassette file if requests were recorded.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.changed {
+		return nil
+	}
+	b, err := json.MarshalIndent(c.interactions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("birdeye: encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("birdeye: save cassette: %w", err)
+	}
+	if err := os.WriteFile(c.path, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("birdeye: save cassette: %w", err)
+	}
+	c.changed = false
+	return nil
+}
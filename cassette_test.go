@@ -0,0 +1,87 @@
+package gobe_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dwdwow/gobe"
+)
+
+func TestCassette(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Set-Cookie", "session="+r.Header.Get("x-api-key"))
+		fmt.Fprintf(w, `{"success":true,"data":{"value":%d,"isScaledUiToken":false,"updateUnixTime":%d}}`, n, n)
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "cassettes", "price.json")
+
+	rec, err := gobe.NewCassette(path, gobe.CASSETTE_RECORD, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt := gobe.NewClient(testSecretKey, nil, gobe.WithBaseURL(srv.URL), gobe.WithCassette(rec))
+	for i := 0; i < 2; i++ {
+		if _, err := clt.PriceCtx(context.Background(), gobe.CHAIN_SOLANA, "token-a", true, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), testSecretKey) {
+		t.Fatalf("api key recorded: %s", b)
+	}
+
+	play, err := gobe.NewCassette(path, gobe.CASSETTE_REPLAY, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clt = gobe.NewClient(testSecretKey, nil, gobe.WithBaseURL("http://127.0.0.1:1"), gobe.WithCassette(play),
+		gobe.WithRetry(gobe.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	// identical requests replay in order, the last one repeating
+	for _, expected := range []float64{1, 2, 2} {
+		price, err := clt.PriceCtx(context.Background(), gobe.CHAIN_SOLANA, "token-a", true, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if price.Value != expected {
+			t.Fatalf("expected replayed price %v, got %v", expected, price.Value)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 requests to the server, got %d", calls.Load())
+	}
+
+	// another chain does not match, misses are not retried
+	_, err = clt.PriceCtx(context.Background(), gobe.CHAIN_ETHEREUM, "token-a", true, 0)
+	if !errors.Is(err, gobe.ErrCassetteMiss) || strings.Contains(err.Error(), "attempts") {
+		t.Fatalf("expected single attempt cassette miss, got %v", err)
+	}
+	if misses := play.Misses(); len(misses) != 1 || misses[0].Chain != gobe.CHAIN_ETHEREUM {
+		t.Fatalf("expected 1 miss on ethereum, got %+v", misses)
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	a, _ := url.ParseQuery("b=2&a=1&list=y&list=x")
+	b, _ := url.ParseQuery("list=x&a=1&list=y&b=2&x-api-key=k")
+	if gobe.NormalizeQuery(a) != gobe.NormalizeQuery(b) {
+		t.Fatalf("expected equal queries, got %q and %q", gobe.NormalizeQuery(a), gobe.NormalizeQuery(b))
+	}
+}
@@ -0,0 +1,185 @@
+package gobe
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var chartTypeDurations = map[ChartType]time.Duration{
+	CHART_1m:  time.Minute,
+	CHART_3m:  3 * time.Minute,
+	CHART_5m:  5 * time.Minute,
+	CHART_15m: 15 * time.Minute,
+	CHART_30m: 30 * time.Minute,
+	CHART_1H:  time.Hour,
+	CHART_2H:  2 * time.Hour,
+	CHART_4H:  4 * time.Hour,
+	CHART_6H:  6 * time.Hour,
+	CHART_8H:  8 * time.Hour,
+	CHART_12H: 12 * time.Hour,
+	CHART_1D:  24 * time.Hour,
+	CHART_3D:  3 * 24 * time.Hour,
+	CHART_1W:  7 * 24 * time.Hour,
+	CHART_1M:  30 * 24 * time.Hour,
+}
+
+// ParseChartType parses "1m", "15m", "4H", "1D", "1W", "1M"...
+// Hours, days and weeks are case-insensitive ("4h" is CHART_4H),
+// minutes and months are not, "1m" is one minute and "1M" is one month.
+func ParseChartType(s string) (ChartType, error) {
+	c := ChartType(s)
+	if c.Valid() {
+		return c, nil
+	}
+	if n := len(s); n > 1 {
+		switch s[n-1] {
+		case 'h', 'd', 'w':
+			c = ChartType(s[:n-1] + strings.ToUpper(s[n-1:]))
+			if c.Valid() {
+				return c, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("birdeye: invalid chart type %q", s)
+}
+
+// Valid reports whether c is one of the CHART_* constants.
+func (c ChartType) Valid() bool {
+	_, ok := chartTypeDurations[c]
+	return ok
+}
+
+// Duration returns the length of one candle, 0 for an invalid chart type.
+// CHART_1M returns a nominal 30 days, use Truncate and Next for calendar months.
+func (c ChartType) Duration() time.Duration {
+	return chartTypeDurations[c]
+}
+
+// Truncate returns the start of the candle containing t, in UTC.
+// Candles are aligned to the unix epoch, weeks start on Monday and months on the first day.
+func (c ChartType) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	switch c {
+	case CHART_1M:
+		y, m, _ := t.Date()
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case CHART_1W:
+		y, m, d := t.Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	secs := int64(c.Duration() / time.Second)
+	if secs == 0 {
+		return t
+	}
+	u := t.Unix()
+	mod := u % secs
+	if mod < 0 {
+		mod += secs
+	}
+	return time.Unix(u-mod, 0).UTC()
+}
+
+// Next returns the start of the candle after the one containing t.
+func (c ChartType) Next(t time.Time) time.Time {
+	return c.add(c.Truncate(t), 1)
+}
+
+// BucketsBetween returns the number of candles covering from..to, both inclusive.
+func (c ChartType) BucketsBetween(from, to time.Time) int {
+	if to.Before(from) || !c.Valid() {
+		return 0
+	}
+	start, end := c.Truncate(from), c.Truncate(to)
+	if c != CHART_1M {
+		return int(end.Sub(start)/c.Duration()) + 1
+	}
+	return (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+}
+
+// add moves t by n candles, months are moved by calendar.
+func (c ChartType) add(t time.Time, n int) time.Time {
+	if c == CHART_1M {
+		return t.AddDate(0, n, 0)
+	}
+	return t.Add(time.Duration(n) * c.Duration())
+}
+
+// addUnix is add for unix seconds.
+func (c ChartType) addUnix(t int64, n int) int64 {
+	return c.add(time.Unix(t, 0).UTC(), n).Unix()
+}
+
+// validateTimeRange checks chartType, timeFrom and timeTo of the OHLCV endpoints.
+func validateTimeRange(chartType ChartType, timeFrom, timeTo int64) error {
+	if !chartType.Valid() {
+		return fmt.Errorf("birdeye: invalid chart type %q", chartType)
+	}
+	if timeFrom < 0 || timeTo < 0 {
+		return fmt.Errorf("birdeye: negative time range %d-%d", timeFrom, timeTo)
+	}
+	if timeTo < timeFrom {
+		return fmt.Errorf("birdeye: timeTo %d is before timeFrom %d", timeTo, timeFrom)
+	}
+	return nil
+}
+
+var timeTypeDurations = map[TimeType]time.Duration{
+	TIME_1h:  time.Hour,
+	TIME_2h:  2 * time.Hour,
+	TIME_4h:  4 * time.Hour,
+	TIME_8h:  8 * time.Hour,
+	TIME_24h: 24 * time.Hour,
+}
+
+// ParseTimeType parses "1h", "2h", "4h", "8h" or "24h", case-insensitive.
+func ParseTimeType(s string) (TimeType, error) {
+	t := TimeType(strings.ToLower(s))
+	if !t.Valid() {
+		return "", fmt.Errorf("birdeye: invalid time type %q", s)
+	}
+	return t, nil
+}
+
+// Valid reports whether t is one of the TIME_* constants.
+func (t TimeType) Valid() bool {
+	_, ok := timeTypeDurations[t]
+	return ok
+}
+
+// Duration returns the length of the period, 0 for an invalid time type.
+func (t TimeType) Duration() time.Duration {
+	return timeTypeDurations[t]
+}
+
+var topTradersTimeFrameDurations = map[TopTradersTimeFrame]time.Duration{
+	TOP_TRADERS_TIME_30M: 30 * time.Minute,
+	TOP_TRADERS_TIME_1H:  time.Hour,
+	TOP_TRADERS_TIME_2H:  2 * time.Hour,
+	TOP_TRADERS_TIME_4H:  4 * time.Hour,
+	TOP_TRADERS_TIME_6H:  6 * time.Hour,
+	TOP_TRADERS_TIME_8H:  8 * time.Hour,
+	TOP_TRADERS_TIME_12H: 12 * time.Hour,
+	TOP_TRADERS_TIME_24H: 24 * time.Hour,
+}
+
+// ParseTopTradersTimeFrame parses "30m", "1h"..."24h", case-insensitive.
+func ParseTopTradersTimeFrame(s string) (TopTradersTimeFrame, error) {
+	f := TopTradersTimeFrame(strings.ToLower(s))
+	if !f.Valid() {
+		return "", fmt.Errorf("birdeye: invalid top traders time frame %q", s)
+	}
+	return f, nil
+}
+
+// Valid reports whether f is one of the TOP_TRADERS_TIME_* constants.
+func (f TopTradersTimeFrame) Valid() bool {
+	_, ok := topTradersTimeFrameDurations[f]
+	return ok
+}
+
+// Duration returns the length of the time frame, 0 for an invalid time frame.
+func (f TopTradersTimeFrame) Duration() time.Duration {
+	return topTradersTimeFrameDurations[f]
+}
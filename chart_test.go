@@ -0,0 +1,93 @@
+package gobe_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dwdwow/gobe"
+)
+
+func TestParseChartType(t *testing.T) {
+	cases := map[string]gobe.ChartType{
+		"1m":  gobe.CHART_1m,
+		"1M":  gobe.CHART_1M,
+		"4H":  gobe.CHART_4H,
+		"4h":  gobe.CHART_4H,
+		"1d":  gobe.CHART_1D,
+		"1W":  gobe.CHART_1W,
+		"15m": gobe.CHART_15m,
+	}
+	for s, expected := range cases {
+		c, err := gobe.ParseChartType(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != expected {
+			t.Fatalf("%s: expected %s, got %s", s, expected, c)
+		}
+	}
+	for _, s := range []string{"", "2m", "15M", "1y"} {
+		if _, err := gobe.ParseChartType(s); err == nil {
+			t.Fatalf("%q: expected error", s)
+		}
+	}
+}
+
+func TestChartTypeTruncateNext(t *testing.T) {
+	// Wednesday
+	ts := time.Date(2024, 2, 14, 13, 47, 12, 0, time.UTC)
+	cases := []struct {
+		chart gobe.ChartType
+		start time.Time
+		next  time.Time
+	}{
+		{gobe.CHART_1m, time.Date(2024, 2, 14, 13, 47, 0, 0, time.UTC), time.Date(2024, 2, 14, 13, 48, 0, 0, time.UTC)},
+		{gobe.CHART_15m, time.Date(2024, 2, 14, 13, 45, 0, 0, time.UTC), time.Date(2024, 2, 14, 14, 0, 0, 0, time.UTC)},
+		{gobe.CHART_4H, time.Date(2024, 2, 14, 12, 0, 0, 0, time.UTC), time.Date(2024, 2, 14, 16, 0, 0, 0, time.UTC)},
+		{gobe.CHART_1D, time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)},
+		{gobe.CHART_1W, time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 19, 0, 0, 0, 0, time.UTC)},
+		{gobe.CHART_1M, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		if start := c.chart.Truncate(ts); !start.Equal(c.start) {
+			t.Fatalf("%s: expected start %s, got %s", c.chart, c.start, start)
+		}
+		if next := c.chart.Next(ts); !next.Equal(c.next) {
+			t.Fatalf("%s: expected next %s, got %s", c.chart, c.next, next)
+		}
+	}
+}
+
+func TestChartTypeBucketsBetween(t *testing.T) {
+	from := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if n := gobe.CHART_1M.BucketsBetween(from, to); n != 3 {
+		t.Fatalf("expected 3 months, got %d", n)
+	}
+	if n := gobe.CHART_1D.BucketsBetween(from, to); n != 31 {
+		t.Fatalf("expected 31 days, got %d", n)
+	}
+	if n := gobe.CHART_1m.BucketsBetween(from, from.Add(59*time.Second)); n != 1 {
+		t.Fatalf("expected 1 minute, got %d", n)
+	}
+	if n := gobe.CHART_1m.BucketsBetween(to, from); n != 0 {
+		t.Fatalf("expected 0 for reversed range, got %d", n)
+	}
+}
+
+func TestTimeTypeDuration(t *testing.T) {
+	tt, err := gobe.ParseTimeType("24H")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tt.Duration() != 24*time.Hour {
+		t.Fatalf("unexpected duration %s", tt.Duration())
+	}
+	f, err := gobe.ParseTopTradersTimeFrame("30m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Duration() != 30*time.Minute {
+		t.Fatalf("unexpected duration %s", f.Duration())
+	}
+}
@@ -3,11 +3,17 @@ package gobe
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/dwdwow/golimiter"
@@ -31,6 +37,9 @@ var (
 	ErrUnprocessableEntity = fmt.Errorf("please check the provided data and try again")
 	ErrTooManyRequests     = fmt.Errorf("too many requests")
 	ErrInternalServer      = fmt.Errorf("something went wrong on the server")
+	ErrBadGateway          = fmt.Errorf("bad gateway")
+	ErrServiceUnavailable  = fmt.Errorf("service unavailable")
+	ErrGatewayTimeout      = fmt.Errorf("gateway timeout")
 )
 
 var statusCodeToError = map[int]error{
@@ -40,6 +49,96 @@ var statusCodeToError = map[int]error{
 	http.StatusUnprocessableEntity: ErrUnprocessableEntity,
 	http.StatusTooManyRequests:     ErrTooManyRequests,
 	http.StatusInternalServerError: ErrInternalServer,
+	http.StatusBadGateway:          ErrBadGateway,
+	http.StatusServiceUnavailable:  ErrServiceUnavailable,
+	http.StatusGatewayTimeout:      ErrGatewayTimeout,
+}
+
+// maxErrorBodySize caps how much of a non-200 response body is kept in APIError.
+const maxErrorBodySize = 64 << 10
+
+// APIError is returned for every non-200 response.
+// It wraps one of the Err* sentinels when the status code is known,
+// so both errors.Is(err, ErrTooManyRequests) and errors.As(err, &apiErr) work.
+type APIError struct {
+	StatusCode int
+	// Path is the endpoint path, e.g. "/defi/price"
+	Path string
+	// Chain is the x-chain header of the request, empty if not set
+	Chain string
+	// Message is the birdeye "message" field, or the raw body if it is not json
+	Message string
+	// Body is the raw response body, truncated to 64KB
+	Body []byte
+	// RetryAfter is parsed from the Retry-After header, 0 if absent
+	RetryAfter time.Duration
+	// Err is the sentinel for StatusCode, nil for unmapped status codes
+	Err error
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+	if e.Chain != "" {
+		return fmt.Sprintf("birdeye: status code: %d, path: %s, chain: %s, message: %s", e.StatusCode, e.Path, e.Chain, msg)
+	}
+	return fmt.Sprintf("birdeye: status code: %d, path: %s, message: %s", e.StatusCode, e.Path, msg)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Retryable reports whether the request may succeed if sent again,
+// true for 408, 429 and 5xx except 501, false for the other (permanent) errors.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented:
+		return false
+	}
+	return e.StatusCode >= 500
+}
+
+func newAPIError(resp *http.Response, path string, chains []string) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Path:       path,
+		Chain:      strings.Join(chains, ","),
+		Body:       body,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Err:        statusCodeToError[resp.StatusCode],
+	}
+	var rd RespData[json.RawMessage]
+	if err := json.Unmarshal(body, &rd); err == nil {
+		e.Message = rd.Message
+	} else {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or http-date form.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
 }
 
 type ChainType string
@@ -769,30 +868,204 @@ type RespWalletPortfolio struct {
 }
 
 type Client struct {
-	apiKey  string
-	limiter *golimiter.ReqLimiter
+	apiKey     Secret
+	limiter    *golimiter.ReqLimiter
+	httpClient *http.Client
+	baseURL    string
+	userAgent  string
+	headers    http.Header
+	retry      RetryPolicy
+	// logger logs every request when set, with the api key redacted
+	logger *slog.Logger
+}
+
+// RetryPolicy controls how failed requests are retried.
+// Only retryable APIErrors (429, 5xx...) and transport errors are retried,
+// the limiter is waited on again before every attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one, <= 1 disables retry
+	MaxAttempts int
+	// BaseDelay is the delay before the second attempt, doubled for every following attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay, <= 0 caps it at maxRetryDelay.
+	// A longer Retry-After from the server is honoured up to MaxRetryAfter.
+	MaxDelay time.Duration
+	// MaxRetryAfter caps the Retry-After of the server, <= 0 caps it like the backoff delay
+	MaxRetryAfter time.Duration
+}
+
+// maxRetryDelay caps the backoff of policies without MaxDelay, so doubling never overflows.
+const maxRetryDelay = time.Hour
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:   5,
+	BaseDelay:     500 * time.Millisecond,
+	MaxDelay:      30 * time.Second,
+	MaxRetryAfter: 2 * time.Minute,
+}
+
+// delay returns the exponential backoff with jitter after the given attempt.
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = maxRetryDelay
+	}
+	d := min(p.BaseDelay, maxDelay)
+	for i := 1; i < attempt && d > 0 && d < maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, maxDelay)
+	if d > 0 {
+		d = d/2 + time.Duration(rand.Int64N(int64(d/2)+1))
+	}
+	maxRetryAfter := p.MaxRetryAfter
+	if maxRetryAfter <= 0 {
+		maxRetryAfter = maxDelay
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		d = max(d, min(apiErr.RetryAfter, maxRetryAfter))
+	}
+	return d
+}
+
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	// a cassette miss is returned by the transport but never succeeds on retry
+	if errors.Is(err, ErrCassetteMiss) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	// transport errors like a bad certificate, an unknown host or an unsupported scheme fail again
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ClientOption configures a Client created by NewClient.
+type ClientOption func(*Client)
+
+// WithHTTPClient sets the http.Client used for requests, default is http.DefaultClient.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		if hc != nil {
+			c.httpClient = hc
+		}
+	}
+}
+
+// WithTransport sets the RoundTripper used for requests, keeping the other settings of the http.Client.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		hc := *c.httpClient
+		hc.Transport = rt
+		c.httpClient = &hc
+	}
+}
+
+// WithBaseURL overrides BASE_URL, e.g. to point at an httptest server or a caching proxy.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
 }
 
-func NewClient(apiKey string, limiter *golimiter.ReqLimiter) *Client {
-	return &Client{
-		apiKey:  apiKey,
-		limiter: limiter,
+// WithRetry enables retrying of failed requests, see RetryPolicy.
+func WithRetry(p RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retry = p
 	}
 }
 
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(ua string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithLogger logs every request at debug level and failed requests at warn level.
+// The api key is redacted from every logged url, header and error.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithHeader adds an extra header to every request.
+// The api key, content type and chain headers can not be overridden.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.headers.Add(key, value)
+	}
+}
+
+func NewClient(apiKey string, limiter *golimiter.ReqLimiter, opts ...ClientOption) *Client {
+	c := &Client{
+		apiKey:     Secret(apiKey),
+		limiter:    limiter,
+		httpClient: http.DefaultClient,
+		baseURL:    BASE_URL,
+		headers:    http.Header{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.logger != nil {
+		c.logger = redactLogger(c.logger, c.apiKey)
+	}
+	return c
+}
+
 func (c *Client) newHeader(chains ...string) http.Header {
-	header := http.Header{}
+	header := c.headers.Clone()
+	if c.userAgent != "" {
+		header.Set("user-agent", c.userAgent)
+	}
 	header.Set("content-type", "application/json")
-	header.Set("x-api-key", c.apiKey)
+	header.Set("x-api-key", c.apiKey.Reveal())
 	if len(chains) > 0 {
 		header.Set("x-chain", strings.Join(chains, ","))
 	}
 	return header
 }
 
+// wait blocks until the limiter admits a request or ctx is done.
+func (c *Client) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.limiter == nil {
+		return nil
+	}
+	c.limiter.Wait(ctx)
+	// Wait reports nothing, ctx may have been done while waiting, the request must not be sent then
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return nil
+}
+
 func get[D any](ctx context.Context, clt *Client, path string, chains []string, params ...any) (D, error) {
-	clt.limiter.Wait(ctx)
-	ul := fmt.Sprintf("%s%s", BASE_URL, path)
+	ul := fmt.Sprintf("%s%s", clt.baseURL, path)
 	ps := url.Values{}
 	for i := 0; i < len(params)-1; i += 2 {
 		key := fmt.Sprintf("%v", params[i])
@@ -812,47 +1085,86 @@ func get[D any](ctx context.Context, clt *Client, path string, chains []string,
 		ps.Add(key, value)
 	}
 	ul += "?" + ps.Encode()
+
+	// all requests are idempotent GETs, so they are safe to retry
+	attempt := 1
+	for {
+		d, err := getOnce[D](ctx, clt, ul, path, chains)
+		if err == nil {
+			return d, nil
+		}
+		err = clt.apiKey.RedactError(err)
+		if attempt >= clt.retry.MaxAttempts || !isRetryable(ctx, err) {
+			if attempt > 1 {
+				err = fmt.Errorf("birdeye: failed after %d attempts: %w", attempt, err)
+			}
+			return d, err
+		}
+		if werr := sleepCtx(ctx, clt.retry.delay(attempt, err)); werr != nil {
+			return d, fmt.Errorf("birdeye: failed after %d attempts: %w: %w", attempt, werr, err)
+		}
+		attempt++
+	}
+}
+
+func getOnce[D any](ctx context.Context, clt *Client, ul string, path string, chains []string) (D, error) {
+	if err := clt.wait(ctx); err != nil {
+		return *new(D), fmt.Errorf("birdeye: wait limiter: %w", err)
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", ul, nil)
 	if err != nil {
 		return *new(D), fmt.Errorf("birdeye: new request: %w", err)
 	}
 	req.Header = clt.newHeader(chains...)
-	resp, err := http.DefaultClient.Do(req)
+	start := time.Now()
+	resp, err := clt.httpClient.Do(req)
+	if clt.logger != nil {
+		clt.logRequest(ctx, req, resp, err, time.Since(start))
+	}
 	if err != nil {
 		return *new(D), fmt.Errorf("birdeye: do request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	statusCode := resp.StatusCode
-
-	err = statusCodeToError[statusCode]
-	if err != nil {
-		return *new(D), fmt.Errorf("birdeye: status code: %d, message: %s", statusCode, err)
+	if resp.StatusCode != http.StatusOK {
+		return *new(D), newAPIError(resp, path, chains)
 	}
 
-	// body, err := io.ReadAll(resp.Body)
-	// if err != nil {
-	// 	return *new(D), fmt.Errorf("birdeye: read response: %w", err)
-	// }
-	// fmt.Println(string(body))
-
 	var rd RespData[D]
 	if err := json.NewDecoder(resp.Body).Decode(&rd); err != nil {
 		return *new(D), fmt.Errorf("birdeye: decode response: %w", err)
 	}
 
-	if statusCode == http.StatusOK {
-		return rd.Data, nil
-	}
+	return rd.Data, nil
+}
 
-	return *new(D), fmt.Errorf("birdeye: status code: %d, message: %s", statusCode, rd.Message)
+func (c *Client) logRequest(ctx context.Context, req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	if err != nil {
+		c.logger.WarnContext(ctx, "birdeye: request failed", "method", req.Method, "url", req.URL, "header", req.Header, "elapsed", elapsed, "error", err)
+		return
+	}
+	level := slog.LevelDebug
+	if resp.StatusCode != http.StatusOK {
+		level = slog.LevelWarn
+	}
+	c.logger.Log(ctx, level, "birdeye: request", "method", req.Method, "url", req.URL, "header", req.Header, "status", resp.StatusCode, "elapsed", elapsed)
 }
 
 func (c *Client) SupportedNetworks() ([]string, error) {
-	return get[[]string](context.Background(), c, "/defi/networks", nil)
+	return c.SupportedNetworksCtx(context.Background())
+}
+
+// SupportedNetworksCtx is like SupportedNetworks but honours ctx while waiting on the limiter and during the request.
+func (c *Client) SupportedNetworksCtx(ctx context.Context) ([]string, error) {
+	return get[[]string](ctx, c, "/defi/networks", nil)
 }
 
 func (c *Client) Price(chain string, token string, includeLiquidity bool, checkLiquidity float64) (RespPrice, error) {
+	return c.PriceCtx(context.Background(), chain, token, includeLiquidity, checkLiquidity)
+}
+
+// PriceCtx is like Price but honours ctx while waiting on the limiter and during the request.
+func (c *Client) PriceCtx(ctx context.Context, chain string, token string, includeLiquidity bool, checkLiquidity float64) (RespPrice, error) {
 	params := []any{"address", token}
 	if includeLiquidity {
 		params = append(params, "include_liquidity", includeLiquidity)
@@ -860,15 +1172,25 @@ func (c *Client) Price(chain string, token string, includeLiquidity bool, checkL
 	if checkLiquidity > 0 {
 		params = append(params, "check_liquidity", checkLiquidity)
 	}
-	return get[RespPrice](context.Background(), c, "/defi/price", []string{chain}, params...)
+	return get[RespPrice](ctx, c, "/defi/price", []string{chain}, params...)
 }
 
 func (c *Client) PriceHistory(chain string, address string, addressType AddressType, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespPriceHistoryItem], error) {
-	return get[RespItems[RespPriceHistoryItem]](context.Background(), c, "/defi/history_price", []string{chain},
+	return c.PriceHistoryCtx(context.Background(), chain, address, addressType, chartType, timeFrom, timeTo)
+}
+
+// PriceHistoryCtx is like PriceHistory but honours ctx while waiting on the limiter and during the request.
+func (c *Client) PriceHistoryCtx(ctx context.Context, chain string, address string, addressType AddressType, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespPriceHistoryItem], error) {
+	return get[RespItems[RespPriceHistoryItem]](ctx, c, "/defi/history_price", []string{chain},
 		"address", address, "address_type", addressType, "type", chartType, "time_from", timeFrom, "time_to", timeTo)
 }
 
 func (c *Client) MultiPrice(chain string, listAddress []string, includeLiquidity bool, checkLiquidity float64) (RespMultiPrice, error) {
+	return c.MultiPriceCtx(context.Background(), chain, listAddress, includeLiquidity, checkLiquidity)
+}
+
+// MultiPriceCtx is like MultiPrice but honours ctx while waiting on the limiter and during the request.
+func (c *Client) MultiPriceCtx(ctx context.Context, chain string, listAddress []string, includeLiquidity bool, checkLiquidity float64) (RespMultiPrice, error) {
 	params := []any{"list_address", listAddress}
 	if includeLiquidity {
 		params = append(params, "include_liquidity", includeLiquidity)
@@ -876,7 +1198,7 @@ func (c *Client) MultiPrice(chain string, listAddress []string, includeLiquidity
 	if checkLiquidity > 0 {
 		params = append(params, "check_liquidity", checkLiquidity)
 	}
-	return get[RespMultiPrice](context.Background(), c, "/defi/multi_price", []string{chain}, params...)
+	return get[RespMultiPrice](ctx, c, "/defi/multi_price", []string{chain}, params...)
 }
 
 // OHLCVByToken retrieves OHLCV (Open, High, Low, Close, Volume) data for a specific token
@@ -892,7 +1214,15 @@ func (c *Client) MultiPrice(chain string, listAddress []string, includeLiquidity
 //   - []RespOHLCVItem: Array of OHLCV data points
 //   - error: Any error that occurred during the request
 func (c *Client) OHLCVByToken(chain string, address string, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespOHLCVItem], error) {
-	return get[RespItems[RespOHLCVItem]](context.Background(), c, "/defi/ohlcv", []string{chain},
+	return c.OHLCVByTokenCtx(context.Background(), chain, address, chartType, timeFrom, timeTo)
+}
+
+// OHLCVByTokenCtx is like OHLCVByToken but honours ctx while waiting on the limiter and during the request.
+func (c *Client) OHLCVByTokenCtx(ctx context.Context, chain string, address string, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespOHLCVItem], error) {
+	if err := validateTimeRange(chartType, timeFrom, timeTo); err != nil {
+		return RespItems[RespOHLCVItem]{}, err
+	}
+	return get[RespItems[RespOHLCVItem]](ctx, c, "/defi/ohlcv", []string{chain},
 		"address", address, "type", chartType, "time_from", timeFrom, "time_to", timeTo)
 }
 
@@ -909,7 +1239,15 @@ func (c *Client) OHLCVByToken(chain string, address string, chartType ChartType,
 //   - []RespOHLCVBaseQuoteItem: Array of OHLCV data points for the trading pair
 //   - error: Any error that occurred during the request
 func (c *Client) OHLCVByPair(chain string, address string, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespOHLCVItem], error) {
-	return get[RespItems[RespOHLCVItem]](context.Background(), c, "/defi/ohlcv/pair", []string{chain},
+	return c.OHLCVByPairCtx(context.Background(), chain, address, chartType, timeFrom, timeTo)
+}
+
+// OHLCVByPairCtx is like OHLCVByPair but honours ctx while waiting on the limiter and during the request.
+func (c *Client) OHLCVByPairCtx(ctx context.Context, chain string, address string, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespOHLCVItem], error) {
+	if err := validateTimeRange(chartType, timeFrom, timeTo); err != nil {
+		return RespItems[RespOHLCVItem]{}, err
+	}
+	return get[RespItems[RespOHLCVItem]](ctx, c, "/defi/ohlcv/pair", []string{chain},
 		"address", address, "type", chartType, "time_from", timeFrom, "time_to", timeTo)
 }
 
@@ -927,7 +1265,15 @@ func (c *Client) OHLCVByPair(chain string, address string, chartType ChartType,
 //   - []RespOHLCVBaseQuoteItem: Array of OHLCV data points for the trading pair
 //   - error: Any error that occurred during the request
 func (c *Client) OHLCVByBaseQuote(chain string, baseAddress string, quoteAddress string, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespOHLCVBaseQuoteItem], error) {
-	return get[RespItems[RespOHLCVBaseQuoteItem]](context.Background(), c, "/defi/ohlcv/base_quote", []string{chain},
+	return c.OHLCVByBaseQuoteCtx(context.Background(), chain, baseAddress, quoteAddress, chartType, timeFrom, timeTo)
+}
+
+// OHLCVByBaseQuoteCtx is like OHLCVByBaseQuote but honours ctx while waiting on the limiter and during the request.
+func (c *Client) OHLCVByBaseQuoteCtx(ctx context.Context, chain string, baseAddress string, quoteAddress string, chartType ChartType, timeFrom, timeTo int64) (RespItems[RespOHLCVBaseQuoteItem], error) {
+	if err := validateTimeRange(chartType, timeFrom, timeTo); err != nil {
+		return RespItems[RespOHLCVBaseQuoteItem]{}, err
+	}
+	return get[RespItems[RespOHLCVBaseQuoteItem]](ctx, c, "/defi/ohlcv/base_quote", []string{chain},
 		"base_address", baseAddress, "quote_address", quoteAddress, "type", chartType, "time_from", timeFrom, "time_to", timeTo)
 }
 
@@ -945,7 +1291,12 @@ func (c *Client) OHLCVByBaseQuote(chain string, baseAddress string, quoteAddress
 //   - RespItems[RespTradesByTokenItem]: Paginated list of trade records
 //   - error: Any error that occurred during the request
 func (c *Client) TradesByToken(chain string, address string, sortType SortType, offset int, limit int, txType TxType) (RespItems[RespTradesByTokenItem], error) {
-	return get[RespItems[RespTradesByTokenItem]](context.Background(), c, "/defi/txs/token", []string{chain},
+	return c.TradesByTokenCtx(context.Background(), chain, address, sortType, offset, limit, txType)
+}
+
+// TradesByTokenCtx is like TradesByToken but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TradesByTokenCtx(ctx context.Context, chain string, address string, sortType SortType, offset int, limit int, txType TxType) (RespItems[RespTradesByTokenItem], error) {
+	return get[RespItems[RespTradesByTokenItem]](ctx, c, "/defi/txs/token", []string{chain},
 		"address", address,
 		"sort_type", sortType,
 		"offset", offset,
@@ -967,7 +1318,12 @@ func (c *Client) TradesByToken(chain string, address string, sortType SortType,
 //   - RespItems[RespTradesByPairItem]: Paginated list of trade records
 //   - error: Any error that occurred during the request
 func (c *Client) TradesByPair(chain string, address string, sortType SortType, offset int, limit int, txType TxType) (RespItems[RespTradesByPairItem], error) {
-	return get[RespItems[RespTradesByPairItem]](context.Background(), c, "/defi/txs/pair", []string{chain},
+	return c.TradesByPairCtx(context.Background(), chain, address, sortType, offset, limit, txType)
+}
+
+// TradesByPairCtx is like TradesByPair but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TradesByPairCtx(ctx context.Context, chain string, address string, sortType SortType, offset int, limit int, txType TxType) (RespItems[RespTradesByPairItem], error) {
+	return get[RespItems[RespTradesByPairItem]](ctx, c, "/defi/txs/pair", []string{chain},
 		"address", address,
 		"sort_type", sortType,
 		"offset", offset,
@@ -986,7 +1342,12 @@ func (c *Client) TradesByPair(chain string, address string, sortType SortType, o
 //   - RespPriceHistoryByTime: Historical price data at the specified timestamp
 //   - error: Any error that occurred during the request
 func (c *Client) HistoricalPriceByUnix(chain string, address string, unixTime int64) (RespPriceHistoryByTime, error) {
-	return get[RespPriceHistoryByTime](context.Background(), c, "/defi/historical_price_unix", []string{chain},
+	return c.HistoricalPriceByUnixCtx(context.Background(), chain, address, unixTime)
+}
+
+// HistoricalPriceByUnixCtx is like HistoricalPriceByUnix but honours ctx while waiting on the limiter and during the request.
+func (c *Client) HistoricalPriceByUnixCtx(ctx context.Context, chain string, address string, unixTime int64) (RespPriceHistoryByTime, error) {
+	return get[RespPriceHistoryByTime](ctx, c, "/defi/historical_price_unix", []string{chain},
 		"address", address,
 		"unixtime", unixTime)
 }
@@ -1002,7 +1363,12 @@ func (c *Client) HistoricalPriceByUnix(chain string, address string, unixTime in
 //   - RespSinglePriceVolume: Price and volume data for the specified token and time period
 //   - error: Any error that occurred during the request
 func (c *Client) PriceVolumeByToken(chain string, address string, timeType TimeType) (RespSinglePriceVolume, error) {
-	return get[RespSinglePriceVolume](context.Background(), c, "/defi/price_volume/single", []string{chain},
+	return c.PriceVolumeByTokenCtx(context.Background(), chain, address, timeType)
+}
+
+// PriceVolumeByTokenCtx is like PriceVolumeByToken but honours ctx while waiting on the limiter and during the request.
+func (c *Client) PriceVolumeByTokenCtx(ctx context.Context, chain string, address string, timeType TimeType) (RespSinglePriceVolume, error) {
+	return get[RespSinglePriceVolume](ctx, c, "/defi/price_volume/single", []string{chain},
 		"address", address,
 		"type", timeType)
 }
@@ -1018,7 +1384,12 @@ func (c *Client) PriceVolumeByToken(chain string, address string, timeType TimeT
 //   - []RespSinglePriceVolume: Price and volume data for the specified tokens and time period
 //   - error: Any error that occurred during the request
 func (c *Client) PriceVolumeByTokens(chain string, listAddress []string, timeType TimeType) ([]RespSinglePriceVolume, error) {
-	return get[[]RespSinglePriceVolume](context.Background(), c, "/defi/price_volume/multi", []string{chain},
+	return c.PriceVolumeByTokensCtx(context.Background(), chain, listAddress, timeType)
+}
+
+// PriceVolumeByTokensCtx is like PriceVolumeByTokens but honours ctx while waiting on the limiter and during the request.
+func (c *Client) PriceVolumeByTokensCtx(ctx context.Context, chain string, listAddress []string, timeType TimeType) ([]RespSinglePriceVolume, error) {
+	return get[[]RespSinglePriceVolume](ctx, c, "/defi/price_volume/multi", []string{chain},
 		"list_address", listAddress,
 		"type", timeType)
 }
@@ -1036,6 +1407,11 @@ func (c *Client) PriceVolumeByTokens(chain string, listAddress []string, timeTyp
 //   - RespTrendingTokens: List of trending tokens with metadata
 //   - error: Any error that occurred during the request
 func (c *Client) TrendingTokens(chain string, sortBy RankType, sortType SortType, offset int, limit int) (RespTrendingTokens, error) {
+	return c.TrendingTokensCtx(context.Background(), chain, sortBy, sortType, offset, limit)
+}
+
+// TrendingTokensCtx is like TrendingTokens but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TrendingTokensCtx(ctx context.Context, chain string, sortBy RankType, sortType SortType, offset int, limit int) (RespTrendingTokens, error) {
 	if limit > 20 {
 		limit = 20
 	}
@@ -1045,7 +1421,7 @@ func (c *Client) TrendingTokens(chain string, sortBy RankType, sortType SortType
 	if offset < 0 {
 		offset = 0
 	}
-	return get[RespTrendingTokens](context.Background(), c, "/defi/token_trending", []string{chain},
+	return get[RespTrendingTokens](ctx, c, "/defi/token_trending", []string{chain},
 		"sort_by", sortBy,
 		"sort_type", sortType,
 		"offset", offset,
@@ -1069,6 +1445,11 @@ func (c *Client) TrendingTokens(chain string, sortBy RankType, sortType SortType
 //
 // Note: beforeTime and afterTime cannot be used simultaneously
 func (c *Client) TradeByTokenAndTime(chain string, address string, beforeTime, afterTime int64, txType TxType, offset int, limit int) (RespItems[RespTradesByTokenItem], error) {
+	return c.TradeByTokenAndTimeCtx(context.Background(), chain, address, beforeTime, afterTime, txType, offset, limit)
+}
+
+// TradeByTokenAndTimeCtx is like TradeByTokenAndTime but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TradeByTokenAndTimeCtx(ctx context.Context, chain string, address string, beforeTime, afterTime int64, txType TxType, offset int, limit int) (RespItems[RespTradesByTokenItem], error) {
 	if beforeTime > 0 && afterTime > 0 {
 		return RespItems[RespTradesByTokenItem]{}, fmt.Errorf("beforeTime and afterTime cannot be used simultaneously")
 	}
@@ -1100,7 +1481,7 @@ func (c *Client) TradeByTokenAndTime(chain string, address string, beforeTime, a
 		params = append(params, "after_time", afterTime)
 	}
 
-	d, err := get[RespItems[RespTradesByTokenItem]](context.Background(), c, "/defi/txs/token/seek_by_time", []string{chain}, params...)
+	d, err := get[RespItems[RespTradesByTokenItem]](ctx, c, "/defi/txs/token/seek_by_time", []string{chain}, params...)
 	if err != nil {
 		return RespItems[RespTradesByTokenItem]{}, err
 	}
@@ -1130,6 +1511,11 @@ func (c *Client) TradeByTokenAndTime(chain string, address string, beforeTime, a
 //   - For sequential queries, adjust beforeTime/afterTime based on the last record's timestamp
 //   - Maximum of 10,000 records can be retrieved from a specified time point
 func (c *Client) TradesByPairAndTime(chain string, address string, beforeTime, afterTime int64, txType TxType, offset int, limit int) (RespItems[RespTradesByPairItem], error) {
+	return c.TradesByPairAndTimeCtx(context.Background(), chain, address, beforeTime, afterTime, txType, offset, limit)
+}
+
+// TradesByPairAndTimeCtx is like TradesByPairAndTime but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TradesByPairAndTimeCtx(ctx context.Context, chain string, address string, beforeTime, afterTime int64, txType TxType, offset int, limit int) (RespItems[RespTradesByPairItem], error) {
 	if beforeTime > 0 && afterTime > 0 {
 		return RespItems[RespTradesByPairItem]{}, fmt.Errorf("beforeTime and afterTime cannot be used simultaneously (error 422)")
 	}
@@ -1168,7 +1554,7 @@ func (c *Client) TradesByPairAndTime(chain string, address string, beforeTime, a
 		params = append(params, "after_time", afterTime)
 	}
 
-	d, err := get[RespItems[RespTradesByPairItem]](context.Background(), c, "/defi/txs/pair/seek_by_time", []string{chain}, params...)
+	d, err := get[RespItems[RespTradesByPairItem]](ctx, c, "/defi/txs/pair/seek_by_time", []string{chain}, params...)
 	if err != nil {
 		return RespItems[RespTradesByPairItem]{}, err
 	}
@@ -1177,7 +1563,12 @@ func (c *Client) TradesByPairAndTime(chain string, address string, beforeTime, a
 
 // TokenOverview returns detailed information about a token, including price changes, volume, and social metrics
 func (c *Client) TokenOverview(chain string, address string) (RespTokenOverview, error) {
-	return get[RespTokenOverview](context.Background(), c, "/defi/token_overview", []string{chain}, "address", address)
+	return c.TokenOverviewCtx(context.Background(), chain, address)
+}
+
+// TokenOverviewCtx is like TokenOverview but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TokenOverviewCtx(ctx context.Context, chain string, address string) (RespTokenOverview, error) {
+	return get[RespTokenOverview](ctx, c, "/defi/token_overview", []string{chain}, "address", address)
 }
 
 // TokenList retrieves a list of tokens sorted by specified criteria
@@ -1194,6 +1585,11 @@ func (c *Client) TokenOverview(chain string, address string) (RespTokenOverview,
 //   - RespItems[RespToken]: Paginated list of token information
 //   - error: Any error that occurred during the request
 func (c *Client) TokenList(chain string, sortBy TokenListSortType, sortType SortType, offset int, limit int, minLiquidity float64) (RespItems[RespToken], error) {
+	return c.TokenListCtx(context.Background(), chain, sortBy, sortType, offset, limit, minLiquidity)
+}
+
+// TokenListCtx is like TokenList but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TokenListCtx(ctx context.Context, chain string, sortBy TokenListSortType, sortType SortType, offset int, limit int, minLiquidity float64) (RespItems[RespToken], error) {
 	if limit > 50 {
 		limit = 50
 	}
@@ -1218,7 +1614,7 @@ func (c *Client) TokenList(chain string, sortBy TokenListSortType, sortType Sort
 		params = append(params, "min_liquidity", minLiquidity)
 	}
 
-	return get[RespItems[RespToken]](context.Background(), c, "/defi/tokenlist", []string{chain}, params...)
+	return get[RespItems[RespToken]](ctx, c, "/defi/tokenlist", []string{chain}, params...)
 }
 
 // TokenListV2 retrieves a URL to download the complete token list
@@ -1233,7 +1629,12 @@ func (c *Client) TokenList(chain string, sortBy TokenListSortType, sortType Sort
 // Note: The returned URL can be used to download a JSON file containing
 // the complete list of tokens and their metadata for the specified chain.
 func (c *Client) TokenListV2(chain string) (RespTokenListV2Url, error) {
-	return get[RespTokenListV2Url](context.Background(), c, "/defi/v2/tokens/all", []string{chain})
+	return c.TokenListV2Ctx(context.Background(), chain)
+}
+
+// TokenListV2Ctx is like TokenListV2 but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TokenListV2Ctx(ctx context.Context, chain string) (RespTokenListV2Url, error) {
+	return get[RespTokenListV2Url](ctx, c, "/defi/v2/tokens/all", []string{chain})
 }
 
 // TokenSecurity retrieves security information for a specific token
@@ -1246,7 +1647,12 @@ func (c *Client) TokenListV2(chain string) (RespTokenListV2Url, error) {
 //   - RespTokenSecurity: Security information for the token
 //   - error: Any error that occurred during the request
 func (c *Client) TokenSecurity(chain string, address string) (RespTokenSecurity, error) {
-	return get[RespTokenSecurity](context.Background(), c, "/defi/token_security", []string{chain},
+	return c.TokenSecurityCtx(context.Background(), chain, address)
+}
+
+// TokenSecurityCtx is like TokenSecurity but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TokenSecurityCtx(ctx context.Context, chain string, address string) (RespTokenSecurity, error) {
+	return get[RespTokenSecurity](ctx, c, "/defi/token_security", []string{chain},
 		"address", address)
 }
 
@@ -1260,7 +1666,12 @@ func (c *Client) TokenSecurity(chain string, address string) (RespTokenSecurity,
 //   - RespTokenSecurity: Creation information for the token
 //   - error: Any error that occurred during the request
 func (c *Client) TokenCreationInfo(chain string, address string) (RespTokenCreationInfo, error) {
-	return get[RespTokenCreationInfo](context.Background(), c, "/defi/token_creation_info", []string{chain},
+	return c.TokenCreationInfoCtx(context.Background(), chain, address)
+}
+
+// TokenCreationInfoCtx is like TokenCreationInfo but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TokenCreationInfoCtx(ctx context.Context, chain string, address string) (RespTokenCreationInfo, error) {
+	return get[RespTokenCreationInfo](ctx, c, "/defi/token_creation_info", []string{chain},
 		"address", address)
 }
 
@@ -1278,6 +1689,11 @@ func (c *Client) TokenCreationInfo(chain string, address string) (RespTokenCreat
 //   - RespItems[RespToken]: Paginated list of market data
 //   - error: Any error that occurred during the request
 func (c *Client) MarketList(chain string, address string, sortBy MarketListSortType, sortType SortType, offset int, limit int) (RespItems[RespMarketItem], error) {
+	return c.MarketListCtx(context.Background(), chain, address, sortBy, sortType, offset, limit)
+}
+
+// MarketListCtx is like MarketList but honours ctx while waiting on the limiter and during the request.
+func (c *Client) MarketListCtx(ctx context.Context, chain string, address string, sortBy MarketListSortType, sortType SortType, offset int, limit int) (RespItems[RespMarketItem], error) {
 	if limit > 10 {
 		limit = 10
 	}
@@ -1288,7 +1704,7 @@ func (c *Client) MarketList(chain string, address string, sortBy MarketListSortT
 		offset = 0
 	}
 
-	return get[RespItems[RespMarketItem]](context.Background(), c, "/defi/v2/markets", []string{chain},
+	return get[RespItems[RespMarketItem]](ctx, c, "/defi/v2/markets", []string{chain},
 		"address", address,
 		"sort_by", sortBy,
 		"sort_type", sortType,
@@ -1308,6 +1724,11 @@ func (c *Client) MarketList(chain string, address string, sortBy MarketListSortT
 //   - RespItems[RespToken]: Paginated list of newly listed tokens
 //   - error: Any error that occurred during the request
 func (c *Client) NewTokenListing(chain string, timeTo int64, limit int, memePlatformEnabled bool) (RespItems[RespNewTokenListingItem], error) {
+	return c.NewTokenListingCtx(context.Background(), chain, timeTo, limit, memePlatformEnabled)
+}
+
+// NewTokenListingCtx is like NewTokenListing but honours ctx while waiting on the limiter and during the request.
+func (c *Client) NewTokenListingCtx(ctx context.Context, chain string, timeTo int64, limit int, memePlatformEnabled bool) (RespItems[RespNewTokenListingItem], error) {
 	if limit > 10 {
 		limit = 10
 	}
@@ -1324,7 +1745,7 @@ func (c *Client) NewTokenListing(chain string, timeTo int64, limit int, memePlat
 		params = append(params, "meme_platform_enabled", true)
 	}
 
-	return get[RespItems[RespNewTokenListingItem]](context.Background(), c, "/defi/v2/tokens/new_listing", []string{chain}, params...)
+	return get[RespItems[RespNewTokenListingItem]](ctx, c, "/defi/v2/tokens/new_listing", []string{chain}, params...)
 }
 
 // TokenTopTraders retrieves the top traders for a specific token based on volume or trade count
@@ -1342,6 +1763,11 @@ func (c *Client) NewTokenListing(chain string, timeTo int64, limit int, memePlat
 //   - RespItems[RespToken]: Paginated list of top traders
 //   - error: Any error that occurred during the request
 func (c *Client) TokenTopTraders(chain string, address string, sortBy TokenTopTradersSortType, sortType SortType, timeFrame TopTradersTimeFrame, offset int64, limit int64) (RespItems[RespTopTraderItem], error) {
+	return c.TokenTopTradersCtx(context.Background(), chain, address, sortBy, sortType, timeFrame, offset, limit)
+}
+
+// TokenTopTradersCtx is like TokenTopTraders but honours ctx while waiting on the limiter and during the request.
+func (c *Client) TokenTopTradersCtx(ctx context.Context, chain string, address string, sortBy TokenTopTradersSortType, sortType SortType, timeFrame TopTradersTimeFrame, offset int64, limit int64) (RespItems[RespTopTraderItem], error) {
 	if limit > 10 {
 		limit = 10
 	}
@@ -1352,7 +1778,7 @@ func (c *Client) TokenTopTraders(chain string, address string, sortBy TokenTopTr
 		offset = 0
 	}
 
-	return get[RespItems[RespTopTraderItem]](context.Background(), c, "/defi/v2/tokens/top_traders", []string{chain},
+	return get[RespItems[RespTopTraderItem]](ctx, c, "/defi/v2/tokens/top_traders", []string{chain},
 		"address", address,
 		"sort_by", sortBy,
 		"sort_type", sortType,
@@ -1373,6 +1799,11 @@ func (c *Client) TokenTopTraders(chain string, address string, sortBy TokenTopTr
 //   - RespItems[RespTradesByTokenItem]: List of transactions for the wallet
 //   - error: Any error that occurred during the request
 func (c *Client) WalletTxHistories(chain string, wallet string, limit int, before string) (map[ChainType][]RespWalletHistory, error) {
+	return c.WalletTxHistoriesCtx(context.Background(), chain, wallet, limit, before)
+}
+
+// WalletTxHistoriesCtx is like WalletTxHistories but honours ctx while waiting on the limiter and during the request.
+func (c *Client) WalletTxHistoriesCtx(ctx context.Context, chain string, wallet string, limit int, before string) (map[ChainType][]RespWalletHistory, error) {
 	if limit <= 0 {
 		limit = 50
 	}
@@ -1383,7 +1814,7 @@ func (c *Client) WalletTxHistories(chain string, wallet string, limit int, befor
 	if before != "" {
 		params = append(params, "before", before)
 	}
-	return get[map[ChainType][]RespWalletHistory](context.Background(), c, "/v1/wallet/tx_list", []string{chain}, params...)
+	return get[map[ChainType][]RespWalletHistory](ctx, c, "/v1/wallet/tx_list", []string{chain}, params...)
 }
 
 // WalletPortfolio retrieves the token portfolio for a specific wallet address
@@ -1396,5 +1827,10 @@ func (c *Client) WalletTxHistories(chain string, wallet string, limit int, befor
 //   - RespItems[RespToken]: List of tokens held in the wallet
 //   - error: Any error that occurred during the request
 func (c *Client) WalletPortfolio(chain string, wallet string) (RespWalletPortfolio, error) {
-	return get[RespWalletPortfolio](context.Background(), c, "/v1/wallet/token_list", []string{chain}, "wallet", wallet)
+	return c.WalletPortfolioCtx(context.Background(), chain, wallet)
+}
+
+// WalletPortfolioCtx is like WalletPortfolio but honours ctx while waiting on the limiter and during the request.
+func (c *Client) WalletPortfolioCtx(ctx context.Context, chain string, wallet string) (RespWalletPortfolio, error) {
+	return get[RespWalletPortfolio](ctx, c, "/v1/wallet/token_list", []string{chain}, "wallet", wallet)
 }
@@ -0,0 +1,100 @@
+package gobe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{"first attempt", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 1, time.Second / 2, time.Second},
+		{"doubled", RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute}, 4, 4 * time.Second, 8 * time.Second},
+		{"max delay", RetryPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}, 10, 5 * time.Second, 10 * time.Second},
+		// without MaxDelay doubling must not overflow into a negative delay
+		{"no max delay", RetryPolicy{BaseDelay: time.Second}, 100, maxRetryDelay / 2, maxRetryDelay},
+		{"huge base delay", RetryPolicy{BaseDelay: math.MaxInt64}, 3, maxRetryDelay / 2, maxRetryDelay},
+		{"no base delay", RetryPolicy{}, 100, 0, 0},
+	} {
+		for range 100 {
+			if d := tc.policy.delay(tc.attempt, nil); d < tc.min || d > tc.max {
+				t.Fatalf("%s: expected delay in %s-%s, got %s", tc.name, tc.min, tc.max, d)
+			}
+		}
+	}
+
+	// a longer Retry-After is honoured up to MaxRetryAfter
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, MaxRetryAfter: 5 * time.Minute}
+	if d := p.delay(1, &APIError{RetryAfter: 2 * time.Minute}); d != 2*time.Minute {
+		t.Fatalf("expected Retry-After delay, got %s", d)
+	}
+	if d := p.delay(1, &APIError{RetryAfter: 24 * time.Hour}); d != 5*time.Minute {
+		t.Fatalf("expected Retry-After capped at MaxRetryAfter, got %s", d)
+	}
+	p.MaxRetryAfter = 0
+	if d := p.delay(1, &APIError{RetryAfter: 24 * time.Hour}); d != time.Minute {
+		t.Fatalf("expected Retry-After capped at MaxDelay, got %s", d)
+	}
+	p.MaxDelay = 0
+	if d := p.delay(1, &APIError{RetryAfter: 24 * time.Hour}); d != maxRetryDelay {
+		t.Fatalf("expected Retry-After capped at %s, got %s", maxRetryDelay, d)
+	}
+}
+
+// timeoutError is a net.Error timing out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://public-api.birdeye.so/defi/price", Err: err}
+	}
+	opErr := func(err error) error {
+		return urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: err}})
+	}
+	for _, tc := range []struct {
+		name      string
+		err       error
+		retryable bool
+	}{
+		{"server error", &APIError{StatusCode: http.StatusServiceUnavailable, Err: ErrServiceUnavailable}, true},
+		{"bad request", &APIError{StatusCode: http.StatusBadRequest, Err: ErrBadRequest}, false},
+		{"timeout", urlErr(timeoutError{}), true},
+		{"connection reset", opErr(syscall.ECONNRESET), true},
+		{"connection refused", opErr(syscall.ECONNREFUSED), true},
+		{"unexpected eof", urlErr(io.ErrUnexpectedEOF), true},
+		{"unknown host", urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "x", IsNotFound: true}}), false},
+		{"bad certificate", urlErr(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}), false},
+		{"unsupported scheme", urlErr(errors.New(`unsupported protocol scheme "ftp"`)), false},
+		{"transport error", urlErr(errors.New("custom transport failed")), false},
+		{"cassette miss", urlErr(fmt.Errorf("%w: GET /defi/price", ErrCassetteMiss)), false},
+	} {
+		if got := isRetryable(context.Background(), tc.err); got != tc.retryable {
+			t.Errorf("%s: expected retryable %v, got %v", tc.name, tc.retryable, got)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if isRetryable(ctx, urlErr(timeoutError{})) {
+		t.Error("expected no retry after ctx is done")
+	}
+}
@@ -1,45 +1,115 @@
 package gobe_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/dwdwow/gobe"
 )
 
+// to and from are fixed so requests match the recorded cassettes
 var (
-	to   = time.Now()
+	to   = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
 	from = to.Add(-time.Hour)
 )
 
+// cassetteEnv selects the mode of newTestCassette, gobe.CASSETTE_REPLAY if unset.
+const cassetteEnv = "BIRDEYE_CASSETTE"
+
+// cassetteMode returns the mode set by cassetteEnv.
+func cassetteMode() gobe.CassetteMode {
+	if mode := gobe.CassetteMode(os.Getenv(cassetteEnv)); mode != "" {
+		return mode
+	}
+	return gobe.CASSETTE_REPLAY
+}
+
+// newTestCassette returns the cassette of the test, stored in dir under the name of the test.
+//
+// In replay mode the test fails if the cassette was never recorded or if it sends a request
+// not found in the cassette, so it runs without network. Otherwise the cassette is saved when the test ends.
+func newTestCassette(t *testing.T, dir string) *gobe.Cassette {
+	t.Helper()
+	mode := cassetteMode()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	path := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(path); mode == gobe.CASSETTE_REPLAY && errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("no cassette %s, record it with %s=%s", path, cassetteEnv, gobe.CASSETTE_RECORD)
+	}
+	c, err := gobe.NewCassette(path, mode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, miss := range c.Misses() {
+			t.Errorf("request not in cassette %s: %s %s?%s, chain %q", path, miss.Method, miss.Path, miss.Query, miss.Chain)
+		}
+		if err := c.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+	return c
+}
+
+// newTestClient returns a client replaying the cassette of the test from testdata/cassettes.
+// Set BIRDEYE_CASSETTE=record and BIRDEYE_API_KEY to record it against the live api.
+//
+// The committed cassettes are hand-written, not recorded: their values are made up in the shape
+// of the documented responses. They check the request of each endpoint and the decoding of its
+// response, not that birdeye still answers this way.
+func newTestClient(t *testing.T) *gobe.Client {
+	t.Helper()
+	cassette := newTestCassette(t, filepath.Join("testdata", "cassettes"))
+	if cassetteMode() == gobe.CASSETTE_REPLAY {
+		return gobe.NewClient("test-key", nil, gobe.WithCassette(cassette))
+	}
+	return gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter, gobe.WithCassette(cassette))
+}
+
+const (
+	testToken  = "HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC"
+	testPair   = "8sN9549P3Zn6xpQRqpApN57xzkCh6sJxLwuEjcG2W4Ji"
+	testWallet = "C6uq3kFSMDwudLR2ecaWduDYviWEWiJiag7F6A93FyDL"
+)
+
 func TestClientSupportedNetworks(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
+	clt := newTestClient(t)
 	networks, err := clt.SupportedNetworks()
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("networks: %v\n", networks)
+	if !slices.Contains(networks, gobe.CHAIN_SOLANA) {
+		t.Fatalf("networks %v without %s", networks, gobe.CHAIN_SOLANA)
+	}
 }
 
 func TestClientPrice(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	price, err := clt.Price(gobe.CHAIN_SOLANA, "HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC", true, 1000)
+	clt := newTestClient(t)
+	price, err := clt.Price(gobe.CHAIN_SOLANA, testToken, true, 1000)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("price: %+v\n", price)
+	if price.Value <= 0 || price.UpdateUnixTime <= 0 || price.Liquidity <= 0 {
+		t.Fatalf("invalid price %+v", price)
+	}
 }
 
 func TestClientPriceHistory(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	to := time.Now()
-	from := to.Add(-time.Hour)
-	fmt.Println(from.Unix(), to.Unix())
+	clt := newTestClient(t)
 	history, err := clt.PriceHistory(
 		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
+		testToken,
 		gobe.ADDRESS_TYPE_TOKEN,
 		gobe.CHART_1m,
 		from.Unix(),
@@ -48,60 +118,73 @@ func TestClientPriceHistory(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("history: %+v\n", history)
+	if len(history.Items) == 0 {
+		t.Fatal("empty price history")
+	}
+	for _, item := range history.Items {
+		if item.Address != testToken || item.Value <= 0 || item.UnixTime < from.Unix() || item.UnixTime > to.Unix() {
+			t.Fatalf("invalid price history item %+v", item)
+		}
+	}
 }
 
 func TestClientMultiPrice(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	price, err := clt.MultiPrice(
-		gobe.CHAIN_SOLANA,
-		[]string{"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC", "So11111111111111111111111111111111111111112"},
-		true,
-		1000,
-	)
+	clt := newTestClient(t)
+	addresses := []string{testToken, "So11111111111111111111111111111111111111112"}
+	price, err := clt.MultiPrice(gobe.CHAIN_SOLANA, addresses, true, 1000)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("price: %+v\n", price)
+	for _, address := range addresses {
+		if info, ok := price[address]; !ok || info.Value <= 0 || info.UpdateUnixTime <= 0 {
+			t.Fatalf("invalid price of %s: %+v", address, info)
+		}
+	}
+}
+
+// checkOHLCV checks the items are ordered candles of address between from and to.
+func checkOHLCV(t *testing.T, address string, items []gobe.RespOHLCVItem) {
+	t.Helper()
+	if len(items) == 0 {
+		t.Fatal("no ohlcv")
+	}
+	for i, item := range items {
+		if item.Address != address || item.Type != gobe.CHART_1m || item.UnixTime < from.Unix() || item.UnixTime > to.Unix() {
+			t.Fatalf("invalid ohlcv %+v", item)
+		}
+		if item.L <= 0 || item.L > min(item.O, item.C) || item.H < max(item.O, item.C) {
+			t.Fatalf("invalid ohlcv prices %+v", item)
+		}
+		if i > 0 && item.UnixTime <= items[i-1].UnixTime {
+			t.Fatalf("ohlcv not ordered at %d", i)
+		}
+	}
 }
 
 func TestClientOHLCVByToken(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	fmt.Println(from.Unix(), to.Unix())
-	ohlcv, err := clt.OHLCVByToken(
-		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
-		gobe.CHART_1m,
-		from.Unix(),
-		to.Unix(),
-	)
+	clt := newTestClient(t)
+	ohlcv, err := clt.OHLCVByToken(gobe.CHAIN_SOLANA, testToken, gobe.CHART_1m, from.Unix(), to.Unix())
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, ohlcv: %+v\n", len(ohlcv.Items), ohlcv.Items)
+	checkOHLCV(t, testToken, ohlcv.Items)
 }
 
 func TestClientOHLCVByPair(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	ohlcv, err := clt.OHLCVByPair(
-		gobe.CHAIN_SOLANA,
-		"8sN9549P3Zn6xpQRqpApN57xzkCh6sJxLwuEjcG2W4Ji",
-		gobe.CHART_1m,
-		from.Unix(),
-		to.Unix(),
-	)
+	clt := newTestClient(t)
+	ohlcv, err := clt.OHLCVByPair(gobe.CHAIN_SOLANA, testPair, gobe.CHART_1m, from.Unix(), to.Unix())
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, ohlcv: %+v\n", len(ohlcv.Items), ohlcv.Items)
+	checkOHLCV(t, testPair, ohlcv.Items)
 }
 
 func TestClientOHLCVByBaseQuote(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
+	clt := newTestClient(t)
 	ohlcv, err := clt.OHLCVByBaseQuote(
 		gobe.CHAIN_SOLANA,
 		"So11111111111111111111111111111111111111112",
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
+		testToken,
 		gobe.CHART_1m,
 		from.Unix(),
 		to.Unix(),
@@ -109,214 +192,253 @@ func TestClientOHLCVByBaseQuote(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, ohlcv: %+v\n", len(ohlcv.Items), ohlcv.Items)
+	if len(ohlcv.Items) == 0 {
+		t.Fatal("no ohlcv")
+	}
+	for _, item := range ohlcv.Items {
+		if item.UnixTime < from.Unix() || item.UnixTime > to.Unix() || item.L <= 0 || item.H < item.L || item.VBase <= 0 || item.VQuote <= 0 {
+			t.Fatalf("invalid ohlcv %+v", item)
+		}
+	}
 }
 
 func TestClientTradesByToken(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	trades, err := clt.TradesByToken(
-		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
-		gobe.SORT_TYPE_DESC,
-		0,
-		10,
-		gobe.TX_TYPE_ALL,
-	)
+	clt := newTestClient(t)
+	trades, err := clt.TradesByToken(gobe.CHAIN_SOLANA, testToken, gobe.SORT_TYPE_DESC, 0, 10, gobe.TX_TYPE_ALL)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, trades: %+v\n", len(trades.Items), trades.Items)
+	checkTokenTrades(t, trades, 10)
+	for i := 1; i < len(trades.Items); i++ {
+		if trades.Items[i].BlockUnixTime > trades.Items[i-1].BlockUnixTime {
+			t.Fatalf("trades not sorted desc at %d", i)
+		}
+	}
+}
+
+// checkTokenTrades checks trades has between 1 and limit trades of testToken.
+func checkTokenTrades(t *testing.T, trades gobe.RespItems[gobe.RespTradesByTokenItem], limit int) {
+	t.Helper()
+	if len(trades.Items) == 0 || len(trades.Items) > limit {
+		t.Fatalf("got %d trades, limit %d", len(trades.Items), limit)
+	}
+	for _, trade := range trades.Items {
+		if trade.TxHash == "" || trade.Owner == "" || trade.BlockUnixTime <= 0 {
+			t.Fatalf("invalid trade %+v", trade)
+		}
+		if trade.Base.Address != testToken && trade.Quote.Address != testToken {
+			t.Fatalf("trade %s without %s", trade.TxHash, testToken)
+		}
+	}
+}
+
+// checkPairTrades checks trades has between 1 and limit trades of testPair.
+func checkPairTrades(t *testing.T, trades gobe.RespItems[gobe.RespTradesByPairItem], limit int) {
+	t.Helper()
+	if len(trades.Items) == 0 || len(trades.Items) > limit {
+		t.Fatalf("got %d trades, limit %d", len(trades.Items), limit)
+	}
+	for _, trade := range trades.Items {
+		if trade.TxHash == "" || trade.Address != testPair || trade.BlockUnixTime <= 0 || trade.From.Address == "" || trade.To.Address == "" {
+			t.Fatalf("invalid trade %+v", trade)
+		}
+	}
 }
 
 func TestClientTradesByPair(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	trades, err := clt.TradesByPair(
-		gobe.CHAIN_SOLANA,
-		"8sN9549P3Zn6xpQRqpApN57xzkCh6sJxLwuEjcG2W4Ji",
-		gobe.SORT_TYPE_DESC,
-		0,
-		10,
-		gobe.TX_TYPE_ALL,
-	)
+	clt := newTestClient(t)
+	trades, err := clt.TradesByPair(gobe.CHAIN_SOLANA, testPair, gobe.SORT_TYPE_DESC, 0, 10, gobe.TX_TYPE_ALL)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, trades: %+v\n", len(trades.Items), trades.Items)
+	checkPairTrades(t, trades, 10)
 }
 
 func TestClientHistoricalPriceByUnix(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	price, err := clt.HistoricalPriceByUnix(
-		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
-		time.Now().Unix(),
-	)
+	clt := newTestClient(t)
+	price, err := clt.HistoricalPriceByUnix(gobe.CHAIN_SOLANA, testToken, to.Unix())
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("price: %+v\n", price)
+	if price.Value <= 0 || price.UpdateUnixTime <= 0 || price.UpdateUnixTime > to.Unix() {
+		t.Fatalf("invalid price %+v", price)
+	}
 }
 
 func TestClientPriceVolumeByToken(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	price, err := clt.PriceVolumeByToken(
-		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
-		gobe.TIME_1h,
-	)
+	clt := newTestClient(t)
+	price, err := clt.PriceVolumeByToken(gobe.CHAIN_SOLANA, testToken, gobe.TIME_1h)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("price: %+v\n", price)
+	if price.Price <= 0 || price.VolumeUSD <= 0 || price.UpdateUnixTime <= 0 {
+		t.Fatalf("invalid price volume %+v", price)
+	}
 }
 
 func TestClientPriceVolumeByTokens(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	price, err := clt.PriceVolumeByTokens(
-		gobe.CHAIN_SOLANA,
-		[]string{"8sN9549P3Zn6xpQRqpApN57xzkCh6sJxLwuEjcG2W4Ji", "HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC"},
-		gobe.TIME_1h,
-	)
+	clt := newTestClient(t)
+	addresses := []string{testPair, testToken}
+	prices, err := clt.PriceVolumeByTokens(gobe.CHAIN_SOLANA, addresses, gobe.TIME_1h)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("price: %+v\n", price)
+	if len(prices) != len(addresses) {
+		t.Fatalf("got %d price volumes, want %d", len(prices), len(addresses))
+	}
+	i := slices.IndexFunc(prices, func(p gobe.RespSinglePriceVolume) bool { return p.Address == testToken })
+	if i < 0 || prices[i].Price <= 0 || prices[i].VolumeUSD <= 0 {
+		t.Fatalf("invalid price volumes %+v", prices)
+	}
 }
 
 func TestClientTrendingTokens(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	tokens, err := clt.TrendingTokens(
-		gobe.CHAIN_SOLANA,
-		gobe.RANK_LIQUIDITY,
-		gobe.SORT_TYPE_DESC,
-		0,
-		10,
-	)
+	clt := newTestClient(t)
+	tokens, err := clt.TrendingTokens(gobe.CHAIN_SOLANA, gobe.RANK_LIQUIDITY, gobe.SORT_TYPE_DESC, 0, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("tokens: %+v\n", tokens)
+	if len(tokens.Tokens) == 0 || len(tokens.Tokens) > 10 || tokens.UpdateUnixTime <= 0 {
+		t.Fatalf("invalid trending tokens %+v", tokens)
+	}
+	for i, token := range tokens.Tokens {
+		if token.Address == "" || token.Symbol == "" {
+			t.Fatalf("invalid token %+v", token)
+		}
+		if i > 0 && token.Liquidity > tokens.Tokens[i-1].Liquidity {
+			t.Fatalf("tokens not sorted by liquidity at %d", i)
+		}
+	}
 }
 
 func TestTradeByTokenAndTime(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	trades, err := clt.TradeByTokenAndTime(
-		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
-		0,
-		from.Unix(),
-		gobe.TX_TYPE_ALL,
-		0,
-		10,
-	)
+	clt := newTestClient(t)
+	trades, err := clt.TradeByTokenAndTime(gobe.CHAIN_SOLANA, testToken, 0, from.Unix(), gobe.TX_TYPE_ALL, 0, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, trades: %+v\n", len(trades.Items), trades.Items)
+	checkTokenTrades(t, trades, 10)
+	for _, trade := range trades.Items {
+		if trade.BlockUnixTime <= from.Unix() {
+			t.Fatalf("trade %s at %d, not after %d", trade.TxHash, trade.BlockUnixTime, from.Unix())
+		}
+	}
 }
 
 func TestTradesSeekByTime(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	trades, err := clt.TradesByPairAndTime(
-		gobe.CHAIN_SOLANA,
-		"8sN9549P3Zn6xpQRqpApN57xzkCh6sJxLwuEjcG2W4Ji",
-		0,
-		from.Unix(),
-		gobe.TX_TYPE_ALL,
-		0,
-		10,
-	)
+	clt := newTestClient(t)
+	trades, err := clt.TradesByPairAndTime(gobe.CHAIN_SOLANA, testPair, 0, from.Unix(), gobe.TX_TYPE_ALL, 0, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, trades: %+v\n", len(trades.Items), trades.Items)
+	checkPairTrades(t, trades, 10)
+	for _, trade := range trades.Items {
+		if trade.BlockUnixTime <= from.Unix() {
+			t.Fatalf("trade %s at %d, not after %d", trade.TxHash, trade.BlockUnixTime, from.Unix())
+		}
+	}
 }
 
 func TestTokenOverview(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	overview, err := clt.TokenOverview(
-		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
-	)
+	clt := newTestClient(t)
+	overview, err := clt.TokenOverview(gobe.CHAIN_SOLANA, testToken)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("overview: %+v\n", overview)
+	if overview.Address != testToken || overview.Symbol == "" || overview.Decimals <= 0 || overview.Price <= 0 || overview.Liquidity <= 0 {
+		t.Fatalf("invalid overview %+v", overview)
+	}
 }
 
 func TestTokenList(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	tokens, err := clt.TokenList(
-		gobe.CHAIN_SOLANA,
-		gobe.SORT_V24HUSD,
-		gobe.SORT_TYPE_DESC,
-		0,
-		10,
-		0,
-	)
+	clt := newTestClient(t)
+	tokens, err := clt.TokenList(gobe.CHAIN_SOLANA, gobe.SORT_V24HUSD, gobe.SORT_TYPE_DESC, 0, 10, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, tokens: %+v\n", len(tokens.Items), tokens.Items)
+	if len(tokens.Items) == 0 || len(tokens.Items) > 10 {
+		t.Fatalf("got %d tokens, limit 10", len(tokens.Items))
+	}
+	for i, token := range tokens.Items {
+		if token.Address == "" || token.Symbol == "" || token.V24hUSD <= 0 {
+			t.Fatalf("invalid token %+v", token)
+		}
+		if i > 0 && token.V24hUSD > tokens.Items[i-1].V24hUSD {
+			t.Fatalf("tokens not sorted by volume at %d", i)
+		}
+	}
 }
 
 func TestTokenListV2(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
+	clt := newTestClient(t)
 	url, err := clt.TokenListV2(gobe.CHAIN_SOLANA)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("url: %+v\n", url)
+	if !strings.HasPrefix(url.Url, "https://") {
+		t.Fatalf("invalid url %q", url.Url)
+	}
 }
 
 func TestTokenSecurity(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	security, err := clt.TokenSecurity(gobe.CHAIN_SOLANA, "HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC")
+	clt := newTestClient(t)
+	security, err := clt.TokenSecurity(gobe.CHAIN_SOLANA, testToken)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("security: %+v\n", security)
+	if security.TotalSupply <= 0 || security.CreationTime == nil || *security.CreationTime <= 0 || security.Top10HolderPercent <= 0 {
+		t.Fatalf("invalid security %+v", security)
+	}
 }
 
 func TestTokenCreationInfo(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	creationInfo, err := clt.TokenCreationInfo(gobe.CHAIN_SOLANA, "HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC")
+	clt := newTestClient(t)
+	creationInfo, err := clt.TokenCreationInfo(gobe.CHAIN_SOLANA, testToken)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("creationInfo: %+v\n", creationInfo)
+	if creationInfo.TokenAddress != testToken || creationInfo.TxHash == "" || creationInfo.Slot <= 0 || creationInfo.BlockUnixTime <= 0 {
+		t.Fatalf("invalid creation info %+v", creationInfo)
+	}
 }
 
 func TestMarketList(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	markets, err := clt.MarketList(
-		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
-		gobe.SORT_LIQUIDITY,
-		gobe.SORT_TYPE_DESC,
-		0,
-		10,
-	)
+	clt := newTestClient(t)
+	markets, err := clt.MarketList(gobe.CHAIN_SOLANA, testToken, gobe.SORT_LIQUIDITY, gobe.SORT_TYPE_DESC, 0, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, markets: %+v\n", len(markets.Items), markets.Items)
+	if len(markets.Items) == 0 || len(markets.Items) > 10 {
+		t.Fatalf("got %d markets, limit 10", len(markets.Items))
+	}
+	for _, market := range markets.Items {
+		if market.Address == "" || market.Liquidity <= 0 || (market.Base.Address != testToken && market.Quote.Address != testToken) {
+			t.Fatalf("invalid market %+v", market)
+		}
+	}
 }
 
 func TestNewTokenListing(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	listing, err := clt.NewTokenListing(gobe.CHAIN_SOLANA, time.Now().Unix(), 10, false)
+	clt := newTestClient(t)
+	listing, err := clt.NewTokenListing(gobe.CHAIN_SOLANA, to.Unix(), 10, false)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, listing: %+v\n", len(listing.Items), listing.Items)
+	if len(listing.Items) == 0 || len(listing.Items) > 10 {
+		t.Fatalf("got %d new tokens, limit 10", len(listing.Items))
+	}
+	for _, item := range listing.Items {
+		if item.Address == "" || item.LiquidityAddedAt == "" {
+			t.Fatalf("invalid new token %+v", item)
+		}
+	}
 }
 
 func TestTokenTopTraders(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
+	clt := newTestClient(t)
 	topTraders, err := clt.TokenTopTraders(
 		gobe.CHAIN_SOLANA,
-		"HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC",
+		testToken,
 		gobe.SORT_VOLUME,
 		gobe.SORT_TYPE_DESC,
 		gobe.TOP_TRADERS_TIME_24H,
@@ -326,26 +448,200 @@ func TestTokenTopTraders(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, topTraders: %+v\n", len(topTraders.Items), topTraders.Items)
+	if len(topTraders.Items) == 0 || len(topTraders.Items) > 10 {
+		t.Fatalf("got %d top traders, limit 10", len(topTraders.Items))
+	}
+	for i, trader := range topTraders.Items {
+		if trader.Owner == "" || trader.TokenAddress != testToken || trader.Trade != trader.TradeBuy+trader.TradeSell {
+			t.Fatalf("invalid top trader %+v", trader)
+		}
+		if i > 0 && trader.Volume > topTraders.Items[i-1].Volume {
+			t.Fatalf("top traders not sorted by volume at %d", i)
+		}
+	}
 }
 
 func TestWalletTxHistories(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	txHistories, err := clt.WalletTxHistories(gobe.CHAIN_SOLANA, "C6uq3kFSMDwudLR2ecaWduDYviWEWiJiag7F6A93FyDL", 0, "")
+	clt := newTestClient(t)
+	txHistories, err := clt.WalletTxHistories(gobe.CHAIN_SOLANA, testWallet, 0, "")
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Printf("length: %d, txHistories: %+v\n", len(txHistories[gobe.CHAIN_SOLANA]), txHistories)
+	txs := txHistories[gobe.CHAIN_SOLANA]
+	if len(txs) == 0 {
+		t.Fatalf("no %s txs in %+v", gobe.CHAIN_SOLANA, txHistories)
+	}
+	for _, tx := range txs {
+		if tx.TxHash == "" || tx.BlockNumber <= 0 || tx.BlockTime == "" {
+			t.Fatalf("invalid tx %+v", tx)
+		}
+	}
 }
 
 func TestWalletPortfolio(t *testing.T) {
-	clt := gobe.NewClient(os.Getenv("BIRDEYE_API_KEY"), gobe.StarterLimiter)
-	portfolio, err := clt.WalletPortfolio(gobe.CHAIN_SOLANA, "C6uq3kFSMDwudLR2ecaWduDYviWEWiJiag7F6A93FyDL")
+	clt := newTestClient(t)
+	portfolio, err := clt.WalletPortfolio(gobe.CHAIN_SOLANA, testWallet)
 	if err != nil {
 		t.Fatal(err)
 	}
-	fmt.Println("total", portfolio.TotalUsd)
+	if portfolio.Wallet != testWallet || len(portfolio.Items) == 0 {
+		t.Fatalf("invalid portfolio %+v", portfolio)
+	}
+	var total float64
 	for _, item := range portfolio.Items {
-		fmt.Println(item.Symbol, item.ValueUsd)
+		if item.Address == "" || item.Symbol == "" || item.UiAmount <= 0 {
+			t.Fatalf("invalid portfolio item %+v", item)
+		}
+		total += item.ValueUsd
+	}
+	if math.Abs(total-portfolio.TotalUsd) > 0.01*portfolio.TotalUsd {
+		t.Fatalf("total %v, sum of items %v", portfolio.TotalUsd, total)
+	}
+}
+
+func TestClientPriceCtxCanceled(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `{"success":true,"data":{"value":1}}`)
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", gobe.StarterLimiter, gobe.WithBaseURL(srv.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := clt.PriceCtx(ctx, gobe.CHAIN_SOLANA, "HeLp6NuQkmYB4pYWo2zYs22mESHXPQYzXbB8n4V98jwC", false, 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("expected no request sent, got %d", calls.Load())
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/defi/price" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("unexpected api key: %s", r.Header.Get("x-api-key"))
+		}
+		if r.Header.Get("x-chain") != gobe.CHAIN_SOLANA {
+			t.Errorf("unexpected chain: %s", r.Header.Get("x-chain"))
+		}
+		if r.Header.Get("user-agent") != "gobe-test" {
+			t.Errorf("unexpected user agent: %s", r.Header.Get("user-agent"))
+		}
+		if r.Header.Get("x-extra") != "extra" {
+			t.Errorf("unexpected extra header: %s", r.Header.Get("x-extra"))
+		}
+		fmt.Fprint(w, `{"success":true,"data":{"value":1.5,"updateUnixTime":1700000000}}`)
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil,
+		gobe.WithBaseURL(srv.URL),
+		gobe.WithHTTPClient(srv.Client()),
+		gobe.WithUserAgent("gobe-test"),
+		gobe.WithHeader("x-extra", "extra"),
+	)
+	price, err := clt.Price(gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.Value != 1.5 || price.UpdateUnixTime != 1700000000 {
+		t.Fatalf("unexpected price: %+v", price)
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/defi/price":
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"success":false,"message":"Too many requests"}`)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `<html>bad gateway</html>`)
+		}
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	_, err := clt.Price(gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112", false, 0)
+	if !errors.Is(err, gobe.ErrTooManyRequests) {
+		t.Fatalf("expected ErrTooManyRequests, got %v", err)
+	}
+	var apiErr *gobe.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Path != "/defi/price" || apiErr.Chain != gobe.CHAIN_SOLANA {
+		t.Fatalf("unexpected api error: %+v", apiErr)
+	}
+	if apiErr.Message != "Too many requests" || apiErr.RetryAfter != 3*time.Second || !apiErr.Retryable() {
+		t.Fatalf("unexpected api error: %+v", apiErr)
+	}
+
+	_, err = clt.TokenOverview(gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112")
+	if !errors.Is(err, gobe.ErrBadGateway) {
+		t.Fatalf("expected ErrBadGateway, got %v", err)
+	}
+	if !errors.As(err, &apiErr) || apiErr.Message != "<html>bad gateway</html>" || !apiErr.Retryable() {
+		t.Fatalf("unexpected api error: %+v", apiErr)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/defi/price":
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"success":true,"data":{"value":2}}`)
+		case "/defi/token_overview":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"success":false,"message":"bad address"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil,
+		gobe.WithBaseURL(srv.URL),
+		gobe.WithRetry(gobe.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}),
+	)
+
+	price, err := clt.Price(gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.Value != 2 || calls.Load() != 3 {
+		t.Fatalf("unexpected price %+v after %d calls", price, calls.Load())
+	}
+
+	_, err = clt.TokenOverview(gobe.CHAIN_SOLANA, "bad")
+	if !errors.Is(err, gobe.ErrBadRequest) || strings.Contains(err.Error(), "attempts") {
+		t.Fatalf("expected single attempt ErrBadRequest, got %v", err)
+	}
+
+	_, err = clt.TokenSecurity(gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112")
+	if !errors.Is(err, gobe.ErrInternalServer) || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("expected ErrInternalServer after 3 attempts, got %v", err)
+	}
+
+	// a backoff cut short by the context returns both the context error and the last error
+	slow := gobe.NewClient("test-key", nil,
+		gobe.WithBaseURL(srv.URL),
+		gobe.WithRetry(gobe.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = slow.TokenSecurityCtx(ctx, gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112")
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, gobe.ErrInternalServer) || !strings.Contains(err.Error(), "after 1 attempts") {
+		t.Fatalf("expected DeadlineExceeded and ErrInternalServer after 1 attempts, got %v", err)
 	}
 }
@@ -0,0 +1,187 @@
+package gobe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultOHLCVMaxCandles is the number of candles birdeye returns at most for one OHLCV request.
+const DefaultOHLCVMaxCandles = 1000
+
+// OHLCVRangeOptions configures the OHLCV*Range methods.
+type OHLCVRangeOptions struct {
+	// MaxCandles is the number of candles requested per call, default DefaultOHLCVMaxCandles
+	MaxCandles int
+	// Concurrency is the number of chunks fetched at the same time, default 1.
+	// Requests still go through the limiter of the client.
+	Concurrency int
+}
+
+// OHLCVGap is a run of missing candles, From and To are the unix times of the first and last missing candle.
+type OHLCVGap struct {
+	From  int64 `json:"from" bson:"from"`
+	To    int64 `json:"to" bson:"to"`
+	Count int   `json:"count" bson:"count"`
+}
+
+// OHLCVRange is the merged result of an OHLCV range fetch.
+type OHLCVRange[Item any] struct {
+	// Items sorted by UnixTime, de-duplicated
+	Items []Item
+	// Gaps are the missing candles between timeFrom and timeTo.
+	// Birdeye does not return candles without trades, so gaps are not necessarily an error.
+	Gaps []OHLCVGap
+}
+
+// OHLCVByTokenRange is like OHLCVByToken but splits timeFrom..timeTo into chunks
+// of at most MaxCandles candles, merges them and reports missing candles as gaps.
+func (c *Client) OHLCVByTokenRange(ctx context.Context, chain string, address string, chartType ChartType, timeFrom, timeTo int64, opts *OHLCVRangeOptions) (OHLCVRange[RespOHLCVItem], error) {
+	fetch := func(ctx context.Context, from, to int64) (RespItems[RespOHLCVItem], error) {
+		return c.OHLCVByTokenCtx(ctx, chain, address, chartType, from, to)
+	}
+	return ohlcvRange(ctx, chartType, timeFrom, timeTo, opts, fetch, func(item RespOHLCVItem) int64 { return item.UnixTime })
+}
+
+// OHLCVByPairRange is like OHLCVByTokenRange but for a pair address.
+func (c *Client) OHLCVByPairRange(ctx context.Context, chain string, address string, chartType ChartType, timeFrom, timeTo int64, opts *OHLCVRangeOptions) (OHLCVRange[RespOHLCVItem], error) {
+	fetch := func(ctx context.Context, from, to int64) (RespItems[RespOHLCVItem], error) {
+		return c.OHLCVByPairCtx(ctx, chain, address, chartType, from, to)
+	}
+	return ohlcvRange(ctx, chartType, timeFrom, timeTo, opts, fetch, func(item RespOHLCVItem) int64 { return item.UnixTime })
+}
+
+// OHLCVByBaseQuoteRange is like OHLCVByTokenRange but for a base and quote token.
+func (c *Client) OHLCVByBaseQuoteRange(ctx context.Context, chain string, baseAddress string, quoteAddress string, chartType ChartType, timeFrom, timeTo int64, opts *OHLCVRangeOptions) (OHLCVRange[RespOHLCVBaseQuoteItem], error) {
+	fetch := func(ctx context.Context, from, to int64) (RespItems[RespOHLCVBaseQuoteItem], error) {
+		return c.OHLCVByBaseQuoteCtx(ctx, chain, baseAddress, quoteAddress, chartType, from, to)
+	}
+	return ohlcvRange(ctx, chartType, timeFrom, timeTo, opts, fetch, func(item RespOHLCVBaseQuoteItem) int64 { return item.UnixTime })
+}
+
+type ohlcvChunk struct {
+	from, to int64
+}
+
+func ohlcvRange[Item any](ctx context.Context, chartType ChartType, timeFrom, timeTo int64, opts *OHLCVRangeOptions, fetch func(ctx context.Context, from, to int64) (RespItems[Item], error), unixTime func(Item) int64) (OHLCVRange[Item], error) {
+	if err := validateTimeRange(chartType, timeFrom, timeTo); err != nil {
+		return OHLCVRange[Item]{}, err
+	}
+	maxCandles, concurrency := DefaultOHLCVMaxCandles, 1
+	if opts != nil && opts.MaxCandles > 0 {
+		maxCandles = opts.MaxCandles
+	}
+	if opts != nil && opts.Concurrency > 0 {
+		concurrency = opts.Concurrency
+	}
+
+	// chunks end on candle boundaries, so a chunk starting late in a month still holds maxCandles calendar months
+	var chunks []ohlcvChunk
+	for from := timeFrom; from <= timeTo; {
+		next := chartType.add(chartType.Truncate(time.Unix(from, 0)), maxCandles).Unix()
+		chunks = append(chunks, ohlcvChunk{from: from, to: min(next-1, timeTo)})
+		from = next
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]Item, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			d, err := fetch(ctx, chunk.from, chunk.to)
+			if err != nil {
+				errs[i] = fmt.Errorf("birdeye: ohlcv chunk %d-%d: %w", chunk.from, chunk.to, err)
+				cancel()
+				return
+			}
+			results[i] = d.Items
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return OHLCVRange[Item]{}, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return OHLCVRange[Item]{}, err
+	}
+
+	var items []Item
+	for _, r := range results {
+		items = append(items, r...)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return unixTime(items[i]) < unixTime(items[j])
+	})
+	merged := items[:0]
+	for _, item := range items {
+		t := unixTime(item)
+		if t < timeFrom || t > timeTo {
+			continue
+		}
+		if len(merged) > 0 && unixTime(merged[len(merged)-1]) == t {
+			continue
+		}
+		merged = append(merged, item)
+	}
+
+	times := make([]int64, len(merged))
+	for i, item := range merged {
+		times[i] = unixTime(item)
+	}
+	return OHLCVRange[Item]{Items: merged, Gaps: findGaps(chartType, times, timeFrom, timeTo)}, nil
+}
+
+// findGaps reports missing candles between sorted candle times.
+// Leading and trailing gaps are stepped from the first and last candle,
+// so they do not depend on how birdeye aligns candles.
+func findGaps(chartType ChartType, times []int64, timeFrom, timeTo int64) []OHLCVGap {
+	if len(times) == 0 {
+		return []OHLCVGap{{From: timeFrom, To: timeTo, Count: chartType.BucketsBetween(time.Unix(timeFrom, 0), time.Unix(timeTo, 0))}}
+	}
+	var gaps []OHLCVGap
+	if first := chartType.addUnix(times[0], -1); first >= timeFrom {
+		n := 1
+		for chartType.addUnix(times[0], -(n+1)) >= timeFrom {
+			n++
+		}
+		gaps = append(gaps, OHLCVGap{From: chartType.addUnix(times[0], -n), To: first, Count: n})
+	}
+	for i := 1; i < len(times); i++ {
+		expected := chartType.addUnix(times[i-1], 1)
+		if expected >= times[i] {
+			continue
+		}
+		n := 1
+		for chartType.addUnix(times[i-1], n+1) < times[i] {
+			n++
+		}
+		gaps = append(gaps, OHLCVGap{From: expected, To: chartType.addUnix(times[i-1], n), Count: n})
+	}
+	last := times[len(times)-1]
+	if next := chartType.addUnix(last, 1); next <= timeTo {
+		n := 1
+		for chartType.addUnix(last, n+1) <= timeTo {
+			n++
+		}
+		gaps = append(gaps, OHLCVGap{From: next, To: chartType.addUnix(last, n), Count: n})
+	}
+	return gaps
+}
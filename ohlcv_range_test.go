@@ -0,0 +1,114 @@
+package gobe_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dwdwow/gobe"
+)
+
+func TestOHLCVByTokenRange(t *testing.T) {
+	const (
+		timeFrom = int64(1700000040)
+		timeTo   = timeFrom + 3000*60 - 1
+	)
+	missing := map[int64]bool{
+		timeFrom:           true,
+		timeFrom + 1500*60: true,
+		timeFrom + 1501*60: true,
+		timeFrom + 2999*60: true,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, _ := strconv.ParseInt(q.Get("time_from"), 10, 64)
+		to, _ := strconv.ParseInt(q.Get("time_to"), 10, 64)
+		items := []gobe.RespOHLCVItem{}
+		for ts := (from + 59) / 60 * 60; ts <= to && len(items) < gobe.DefaultOHLCVMaxCandles; ts += 60 {
+			if !missing[ts] {
+				items = append(items, gobe.RespOHLCVItem{UnixTime: ts, C: float64(ts)})
+			}
+		}
+		json.NewEncoder(w).Encode(gobe.RespData[gobe.RespItems[gobe.RespOHLCVItem]]{
+			Success: true,
+			Data:    gobe.RespItems[gobe.RespOHLCVItem]{Items: items},
+		})
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	r, err := clt.OHLCVByTokenRange(context.Background(), gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112",
+		gobe.CHART_1m, timeFrom, timeTo, &gobe.OHLCVRangeOptions{Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Items) != 3000-len(missing) {
+		t.Fatalf("expected %d candles, got %d", 3000-len(missing), len(r.Items))
+	}
+	for i := 1; i < len(r.Items); i++ {
+		if r.Items[i].UnixTime <= r.Items[i-1].UnixTime {
+			t.Fatalf("candles not sorted at %d", i)
+		}
+	}
+	expected := []gobe.OHLCVGap{
+		{From: timeFrom, To: timeFrom, Count: 1},
+		{From: timeFrom + 1500*60, To: timeFrom + 1501*60, Count: 2},
+		{From: timeFrom + 2999*60, To: timeFrom + 2999*60, Count: 1},
+	}
+	if len(r.Gaps) != len(expected) {
+		t.Fatalf("expected gaps %+v, got %+v", expected, r.Gaps)
+	}
+	for i := range expected {
+		if r.Gaps[i] != expected[i] {
+			t.Fatalf("expected gaps %+v, got %+v", expected, r.Gaps)
+		}
+	}
+}
+
+func TestOHLCVByTokenRangeMonths(t *testing.T) {
+	// one candle per chunk, chunks starting on the 31st must not skip a month
+	timeFrom := time.Date(2023, 1, 31, 0, 0, 0, 0, time.UTC).Unix()
+	timeTo := time.Date(2023, 6, 30, 0, 0, 0, 0, time.UTC).Unix()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		from, _ := strconv.ParseInt(q.Get("time_from"), 10, 64)
+		to, _ := strconv.ParseInt(q.Get("time_to"), 10, 64)
+		items := []gobe.RespOHLCVItem{}
+		for m := gobe.CHART_1M.Truncate(time.Unix(from, 0)); m.Unix() <= to; m = m.AddDate(0, 1, 0) {
+			if m.Unix() >= from {
+				items = append(items, gobe.RespOHLCVItem{UnixTime: m.Unix()})
+			}
+		}
+		if len(items) > 1 {
+			t.Errorf("chunk %s-%s holds %d months", time.Unix(from, 0).UTC(), time.Unix(to, 0).UTC(), len(items))
+			items = items[:1]
+		}
+		json.NewEncoder(w).Encode(gobe.RespData[gobe.RespItems[gobe.RespOHLCVItem]]{
+			Success: true,
+			Data:    gobe.RespItems[gobe.RespOHLCVItem]{Items: items},
+		})
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	r, err := clt.OHLCVByTokenRange(context.Background(), gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112",
+		gobe.CHART_1M, timeFrom, timeTo, &gobe.OHLCVRangeOptions{MaxCandles: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Gaps) != 0 {
+		t.Fatalf("expected no gaps, got %+v", r.Gaps)
+	}
+	if len(r.Items) != 5 {
+		t.Fatalf("expected the candles of february to june, got %+v", r.Items)
+	}
+	for i, item := range r.Items {
+		if expected := time.Date(2023, time.Month(i+2), 1, 0, 0, 0, 0, time.UTC).Unix(); item.UnixTime != expected {
+			t.Fatalf("expected candle %d at %d, got %d", i, expected, item.UnixTime)
+		}
+	}
+}
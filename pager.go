@@ -0,0 +1,157 @@
+package gobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrOffsetCapReached is returned by Pager.Err when the server still reports more items
+// but the next offset is beyond the maximum offset the endpoint accepts.
+var ErrOffsetCapReached = errors.New("birdeye: offset cap reached, results are truncated")
+
+// PageFetcher fetches one page of items starting at offset.
+type PageFetcher[T any] func(ctx context.Context, offset, limit int) (RespItems[T], error)
+
+// Pager walks an offset based endpoint page by page until HasNext is false
+// or the server offset cap is reached.
+//
+// Usage:
+//
+//	p := clt.TradesByTokenPager(gobe.CHAIN_SOLANA, address, gobe.SORT_TYPE_DESC, gobe.TX_TYPE_SWAP)
+//	for p.Next(ctx) {
+//		for _, item := range p.Page() {
+//			...
+//		}
+//	}
+//	if err := p.Err(); err != nil {
+//		...
+//	}
+type Pager[T any] struct {
+	fetch     PageFetcher[T]
+	limit     int
+	maxOffset int
+
+	offset int
+	page   []T
+	done   bool
+	err    error
+}
+
+// NewPager creates a Pager fetching limit items per page.
+// maxOffset is the largest offset the endpoint accepts, <= 0 means no cap.
+func NewPager[T any](fetch PageFetcher[T], limit, maxOffset int) *Pager[T] {
+	return &Pager[T]{fetch: fetch, limit: limit, maxOffset: maxOffset}
+}
+
+// Next fetches the next page, it returns false when there are no more pages or an error occurred.
+func (p *Pager[T]) Next(ctx context.Context) bool {
+	p.page = nil
+	if p.done {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		p.stop(err)
+		return false
+	}
+	if p.maxOffset > 0 && p.offset > p.maxOffset {
+		p.stop(fmt.Errorf("%w: offset %d > %d", ErrOffsetCapReached, p.offset, p.maxOffset))
+		return false
+	}
+	d, err := p.fetch(ctx, p.offset, p.limit)
+	if err != nil {
+		p.stop(err)
+		return false
+	}
+	if len(d.Items) == 0 {
+		p.done = true
+		return false
+	}
+	p.page = d.Items
+	p.offset += len(d.Items)
+	if !d.HasNext {
+		p.done = true
+	}
+	return true
+}
+
+func (p *Pager[T]) stop(err error) {
+	p.done = true
+	p.err = err
+}
+
+// Page returns the items of the page fetched by the last call to Next.
+func (p *Pager[T]) Page() []T {
+	return p.page
+}
+
+// Offset returns the offset of the next page.
+func (p *Pager[T]) Offset() int {
+	return p.offset
+}
+
+// Err returns the error that stopped the pager, nil if all pages were read.
+func (p *Pager[T]) Err() error {
+	return p.err
+}
+
+// All reads every remaining page.
+// Items read before an error are returned together with the error.
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	for p.Next(ctx) {
+		items = append(items, p.Page()...)
+	}
+	return items, p.Err()
+}
+
+// TradesByTokenPager pages through TradesByToken, 50 items per page up to offset 1000.
+func (c *Client) TradesByTokenPager(chain string, address string, sortType SortType, txType TxType) *Pager[RespTradesByTokenItem] {
+	return NewPager(func(ctx context.Context, offset, limit int) (RespItems[RespTradesByTokenItem], error) {
+		return c.TradesByTokenCtx(ctx, chain, address, sortType, offset, limit, txType)
+	}, 50, 1000)
+}
+
+// TradesByPairPager pages through TradesByPair, 50 items per page up to offset 1000.
+func (c *Client) TradesByPairPager(chain string, address string, sortType SortType, txType TxType) *Pager[RespTradesByPairItem] {
+	return NewPager(func(ctx context.Context, offset, limit int) (RespItems[RespTradesByPairItem], error) {
+		return c.TradesByPairCtx(ctx, chain, address, sortType, offset, limit, txType)
+	}, 50, 1000)
+}
+
+// TokenListPager pages through TokenList, 50 items per page up to offset 1000.
+func (c *Client) TokenListPager(chain string, sortBy TokenListSortType, sortType SortType, minLiquidity float64) *Pager[RespToken] {
+	return NewPager(func(ctx context.Context, offset, limit int) (RespItems[RespToken], error) {
+		return c.TokenListCtx(ctx, chain, sortBy, sortType, offset, limit, minLiquidity)
+	}, 50, 1000)
+}
+
+// MarketListPager pages through MarketList, 10 items per page.
+func (c *Client) MarketListPager(chain string, address string, sortBy MarketListSortType, sortType SortType) *Pager[RespMarketItem] {
+	return NewPager(func(ctx context.Context, offset, limit int) (RespItems[RespMarketItem], error) {
+		return c.MarketListCtx(ctx, chain, address, sortBy, sortType, offset, limit)
+	}, 10, 0)
+}
+
+// TokenTopTradersPager pages through TokenTopTraders, 10 items per page.
+func (c *Client) TokenTopTradersPager(chain string, address string, sortBy TokenTopTradersSortType, sortType SortType, timeFrame TopTradersTimeFrame) *Pager[RespTopTraderItem] {
+	return NewPager(func(ctx context.Context, offset, limit int) (RespItems[RespTopTraderItem], error) {
+		return c.TokenTopTradersCtx(ctx, chain, address, sortBy, sortType, timeFrame, int64(offset), int64(limit))
+	}, 10, 0)
+}
+
+// TrendingTokensPager pages through TrendingTokens, 20 items per page.
+// TrendingTokens has no hasNext flag, so Total is used to detect the last page.
+func (c *Client) TrendingTokensPager(chain string, sortBy RankType, sortType SortType) *Pager[RespTrendingTokensTokenInfo] {
+	return NewPager(func(ctx context.Context, offset, limit int) (RespItems[RespTrendingTokensTokenInfo], error) {
+		d, err := c.TrendingTokensCtx(ctx, chain, sortBy, sortType, offset, limit)
+		if err != nil {
+			return RespItems[RespTrendingTokensTokenInfo]{}, err
+		}
+		return RespItems[RespTrendingTokensTokenInfo]{
+			Items:   d.Tokens,
+			HasNext: len(d.Tokens) == limit && int64(offset+len(d.Tokens)) < d.Total,
+			Total:   d.Total,
+		}, nil
+	}, 20, 0)
+}
@@ -0,0 +1,64 @@
+package gobe_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/dwdwow/gobe"
+)
+
+func TestPagerTradesByToken(t *testing.T) {
+	const total = 120
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		items := []gobe.RespTradesByTokenItem{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			items = append(items, gobe.RespTradesByTokenItem{TxHash: strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(gobe.RespData[gobe.RespItems[gobe.RespTradesByTokenItem]]{
+			Success: true,
+			Data:    gobe.RespItems[gobe.RespTradesByTokenItem]{Items: items, HasNext: offset+limit < total},
+		})
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	items, err := clt.TradesByTokenPager(gobe.CHAIN_SOLANA, "So11111111111111111111111111111111111111112", gobe.SORT_TYPE_DESC, gobe.TX_TYPE_ALL).All(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != total {
+		t.Fatalf("expected %d items, got %d", total, len(items))
+	}
+	for i, item := range items {
+		if item.TxHash != strconv.Itoa(i) {
+			t.Fatalf("unexpected item %d: %s", i, item.TxHash)
+		}
+	}
+}
+
+func TestPagerOffsetCap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		json.NewEncoder(w).Encode(gobe.RespData[gobe.RespItems[gobe.RespToken]]{
+			Success: true,
+			Data:    gobe.RespItems[gobe.RespToken]{Items: make([]gobe.RespToken, limit), HasNext: true},
+		})
+	}))
+	defer srv.Close()
+	clt := gobe.NewClient("test-key", nil, gobe.WithBaseURL(srv.URL))
+
+	items, err := clt.TokenListPager(gobe.CHAIN_SOLANA, gobe.SORT_V24HUSD, gobe.SORT_TYPE_DESC, 0).All(context.Background())
+	if !errors.Is(err, gobe.ErrOffsetCapReached) {
+		t.Fatalf("expected ErrOffsetCapReached, got %v", err)
+	}
+	if len(items) != 1050 {
+		t.Fatalf("expected 1050 items, got %d", len(items))
+	}
+}
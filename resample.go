@@ -0,0 +1,199 @@
+package gobe
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type PartialBucketPolicy int
+
+const (
+	// PARTIAL_BUCKET_KEEP keeps every bucket, even if source candles are missing
+	PARTIAL_BUCKET_KEEP PartialBucketPolicy = iota
+	// PARTIAL_BUCKET_DROP_EDGES drops the first and last bucket if the input starts after
+	// the start of the first bucket or ends before the end of the last bucket
+	PARTIAL_BUCKET_DROP_EDGES
+	// PARTIAL_BUCKET_DROP drops every bucket with fewer source candles than it spans.
+	// Birdeye omits candles without trades, so this also drops buckets of illiquid tokens.
+	PARTIAL_BUCKET_DROP
+)
+
+// ResampleOptions configures ResampleOHLCV and ResampleOHLCVBaseQuote.
+type ResampleOptions struct {
+	// Interval is the length of the output candles, e.g. 10 * time.Minute.
+	// It must be a multiple of the source candle length,
+	// CHART_1W and CHART_1M sources are aligned to the calendar, not the epoch, and need Chart instead.
+	Interval time.Duration
+	// Chart is used instead of Interval when set,
+	// so CHART_1W and CHART_1M candles are aligned to calendar weeks and months.
+	// CHART_1M only accepts sources of at most a day, or CHART_1M, which nest in calendar months.
+	Chart ChartType
+	// Source is the chart type of the input candles.
+	// ResampleOHLCV takes it from the Type of the first candle if empty.
+	Source ChartType
+	// Offset shifts the alignment of Interval buckets from the unix epoch,
+	// e.g. -8 * time.Hour aligns daily candles to midnight in UTC+8.
+	// It can not be used with Chart, whose candles are aligned in UTC.
+	Offset time.Duration
+	// Partial is how incomplete buckets are handled, default PARTIAL_BUCKET_KEEP
+	Partial PartialBucketPolicy
+}
+
+// ResampleOHLCV aggregates candles into candles of a longer interval without api calls.
+// Open is the open of the first candle of a bucket, close the close of the last one,
+// high and low are the extremes and V is summed. The input does not need to be sorted.
+func ResampleOHLCV(items []RespOHLCVItem, opts ResampleOptions) ([]RespOHLCVItem, error) {
+	if opts.Source == "" && len(items) > 0 {
+		opts.Source = items[0].Type
+	}
+	return resample(items, opts, candleOps[RespOHLCVItem]{
+		unixTime: func(item RespOHLCVItem) int64 { return item.UnixTime },
+		open: func(out ChartType, start int64, first RespOHLCVItem) RespOHLCVItem {
+			first.Type = out
+			first.UnixTime = start
+			return first
+		},
+		merge: func(agg *RespOHLCVItem, item RespOHLCVItem) {
+			agg.H = max(agg.H, item.H)
+			agg.L = min(agg.L, item.L)
+			agg.C = item.C
+			agg.V += item.V
+		},
+	})
+}
+
+// ResampleOHLCVBaseQuote is ResampleOHLCV for base/quote candles, VBase and VQuote are summed.
+// opts.Source is required because RespOHLCVBaseQuoteItem has no chart type.
+func ResampleOHLCVBaseQuote(items []RespOHLCVBaseQuoteItem, opts ResampleOptions) ([]RespOHLCVBaseQuoteItem, error) {
+	return resample(items, opts, candleOps[RespOHLCVBaseQuoteItem]{
+		unixTime: func(item RespOHLCVBaseQuoteItem) int64 { return item.UnixTime },
+		open: func(_ ChartType, start int64, first RespOHLCVBaseQuoteItem) RespOHLCVBaseQuoteItem {
+			first.UnixTime = start
+			return first
+		},
+		merge: func(agg *RespOHLCVBaseQuoteItem, item RespOHLCVBaseQuoteItem) {
+			agg.H = max(agg.H, item.H)
+			agg.L = min(agg.L, item.L)
+			agg.C = item.C
+			agg.VBase += item.VBase
+			agg.VQuote += item.VQuote
+		},
+	})
+}
+
+type candleOps[T any] struct {
+	unixTime func(T) int64
+	// open starts a bucket from its first candle
+	open  func(out ChartType, start int64, first T) T
+	merge func(agg *T, item T)
+}
+
+type resampleBucket[T any] struct {
+	start, end int64
+	candle     T
+	count      int
+}
+
+func resample[T any](items []T, opts ResampleOptions, ops candleOps[T]) ([]T, error) {
+	if !opts.Source.Valid() {
+		return nil, fmt.Errorf("birdeye: invalid source chart type %q", opts.Source)
+	}
+	src := opts.Source.Duration()
+	var (
+		out      ChartType
+		truncate func(t int64) int64
+		next     func(start int64) int64
+	)
+	switch {
+	case opts.Chart != "":
+		if !opts.Chart.Valid() {
+			return nil, fmt.Errorf("birdeye: invalid chart type %q", opts.Chart)
+		}
+		if opts.Offset != 0 {
+			return nil, fmt.Errorf("birdeye: resample offset %s can not be used with chart %s", opts.Offset, opts.Chart)
+		}
+		if !nestsIn(opts.Source, opts.Chart) {
+			return nil, fmt.Errorf("birdeye: can not resample %s into %s", opts.Source, opts.Chart)
+		}
+		out = opts.Chart
+		truncate = func(t int64) int64 { return opts.Chart.Truncate(time.Unix(t, 0)).Unix() }
+		next = func(start int64) int64 { return opts.Chart.addUnix(start, 1) }
+	case opts.Interval > 0:
+		if opts.Source == CHART_1W || opts.Source == CHART_1M || opts.Interval < src || opts.Interval%src != 0 || opts.Interval%time.Second != 0 {
+			return nil, fmt.Errorf("birdeye: can not resample %s into %s", opts.Source, opts.Interval)
+		}
+		out = chartTypeOf(opts.Interval)
+		interval, offset := int64(opts.Interval/time.Second), int64(opts.Offset/time.Second)
+		truncate = func(t int64) int64 {
+			mod := (t - offset) % interval
+			if mod < 0 {
+				mod += interval
+			}
+			return t - mod
+		}
+		next = func(start int64) int64 { return start + interval }
+	default:
+		return nil, fmt.Errorf("birdeye: resample interval or chart is required")
+	}
+
+	sorted := make([]T, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return ops.unixTime(sorted[i]) < ops.unixTime(sorted[j])
+	})
+
+	var buckets []*resampleBucket[T]
+	for _, item := range sorted {
+		t := ops.unixTime(item)
+		start := truncate(t)
+		if n := len(buckets); n > 0 && buckets[n-1].start == start {
+			ops.merge(&buckets[n-1].candle, item)
+			buckets[n-1].count++
+			continue
+		}
+		buckets = append(buckets, &resampleBucket[T]{start: start, end: next(start), candle: ops.open(out, start, item), count: 1})
+	}
+
+	srcSecs := int64(src / time.Second)
+	var result []T
+	for i, b := range buckets {
+		switch opts.Partial {
+		case PARTIAL_BUCKET_DROP:
+			if int64(b.count) < (b.end-b.start)/srcSecs {
+				continue
+			}
+		case PARTIAL_BUCKET_DROP_EDGES:
+			if i == 0 && ops.unixTime(sorted[0]) > b.start {
+				continue
+			}
+			if i == len(buckets)-1 && ops.unixTime(sorted[len(sorted)-1])+srcSecs < b.end {
+				continue
+			}
+		}
+		result = append(result, b.candle)
+	}
+	return result, nil
+}
+
+// nestsIn reports whether every src candle is inside one out candle.
+// Months have no fixed length, only candles of at most a day, aligned to the epoch like days, fit in them.
+func nestsIn(src, out ChartType) bool {
+	if out != CHART_1M {
+		return out.Duration() >= src.Duration() && out.Duration()%src.Duration() == 0
+	}
+	return src == CHART_1M || (24*time.Hour)%src.Duration() == 0
+}
+
+// chartTypeOf formats an interval like a chart type, e.g. "10m", "2H", "2D".
+func chartTypeOf(d time.Duration) ChartType {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return ChartType(fmt.Sprintf("%dD", d/(24*time.Hour)))
+	case d%time.Hour == 0:
+		return ChartType(fmt.Sprintf("%dH", d/time.Hour))
+	case d%time.Minute == 0:
+		return ChartType(fmt.Sprintf("%dm", d/time.Minute))
+	}
+	return ChartType(d.String())
+}
@@ -0,0 +1,96 @@
+package gobe_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dwdwow/gobe"
+)
+
+func TestResampleOHLCV(t *testing.T) {
+	const start = int64(1700000400) // aligned to 10 minutes
+	var items []gobe.RespOHLCVItem
+	// 25 one minute candles, deliberately unsorted
+	for i := int64(24); i >= 0; i-- {
+		p := float64(i)
+		items = append(items, gobe.RespOHLCVItem{
+			Address:  "token",
+			Type:     gobe.CHART_1m,
+			UnixTime: start + i*60,
+			O:        p,
+			H:        p + 0.5,
+			L:        p - 0.5,
+			C:        p + 0.1,
+			V:        1,
+		})
+	}
+
+	candles, err := gobe.ResampleOHLCV(items, gobe.ResampleOptions{Interval: 10 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 3 {
+		t.Fatalf("expected 3 candles, got %d", len(candles))
+	}
+	c := candles[1]
+	if c.UnixTime != start+600 || c.Type != "10m" || c.Address != "token" {
+		t.Fatalf("unexpected candle %+v", c)
+	}
+	if c.O != 10 || c.C != 19.1 || c.H != 19.5 || c.L != 9.5 || c.V != 10 {
+		t.Fatalf("unexpected candle %+v", c)
+	}
+	if candles[2].V != 5 {
+		t.Fatalf("unexpected last candle %+v", candles[2])
+	}
+
+	candles, err = gobe.ResampleOHLCV(items, gobe.ResampleOptions{Interval: 10 * time.Minute, Partial: gobe.PARTIAL_BUCKET_DROP_EDGES})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles without the partial last bucket, got %d", len(candles))
+	}
+
+	if _, err := gobe.ResampleOHLCV(items, gobe.ResampleOptions{Interval: 90 * time.Second}); err == nil {
+		t.Fatal("expected error for an interval that is not a multiple of 1m")
+	}
+	for _, source := range []gobe.ChartType{gobe.CHART_1W, gobe.CHART_1M} {
+		opts := gobe.ResampleOptions{Interval: 14 * 24 * time.Hour, Source: source}
+		if _, err := gobe.ResampleOHLCV(items, opts); err == nil {
+			t.Fatalf("expected error for an interval over calendar %s candles", source)
+		}
+	}
+}
+
+func TestResampleOHLCVBaseQuote(t *testing.T) {
+	start := time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC).Unix()
+	var items []gobe.RespOHLCVBaseQuoteItem
+	for i := int64(0); i < 4; i++ {
+		items = append(items, gobe.RespOHLCVBaseQuoteItem{UnixTime: start + i*86400, O: 1, H: 2, L: 0.5, C: 1.5, VBase: 1, VQuote: 2})
+	}
+	if _, err := gobe.ResampleOHLCVBaseQuote(items, gobe.ResampleOptions{Chart: gobe.CHART_1M}); err == nil {
+		t.Fatal("expected error without source chart type")
+	}
+	candles, err := gobe.ResampleOHLCVBaseQuote(items, gobe.ResampleOptions{Chart: gobe.CHART_1M, Source: gobe.CHART_1D})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("expected January and February candles, got %d", len(candles))
+	}
+	// weeks and 3 days candles cross month boundaries
+	for _, src := range []gobe.ChartType{gobe.CHART_3D, gobe.CHART_1W} {
+		if _, err := gobe.ResampleOHLCVBaseQuote(items, gobe.ResampleOptions{Chart: gobe.CHART_1M, Source: src}); err == nil {
+			t.Fatalf("expected error resampling %s into months", src)
+		}
+	}
+	if _, err := gobe.ResampleOHLCVBaseQuote(items, gobe.ResampleOptions{Chart: gobe.CHART_1M, Source: gobe.CHART_1M}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gobe.ResampleOHLCVBaseQuote(items, gobe.ResampleOptions{Chart: gobe.CHART_1M, Source: gobe.CHART_1D, Offset: -8 * time.Hour}); err == nil {
+		t.Fatal("expected error with offset and chart")
+	}
+	if candles[1].UnixTime != time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC).Unix() || candles[1].VBase != 2 || candles[1].VQuote != 4 {
+		t.Fatalf("unexpected candle %+v", candles[1])
+	}
+}
@@ -0,0 +1,176 @@
+package gobe
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// REDACTED replaces secrets in logs and errors.
+const REDACTED = "[REDACTED]"
+
+// Secret is a credential like the api key, formatting, marshaling or logging it never prints its value.
+type Secret string
+
+func (s Secret) String() string {
+	return REDACTED
+}
+
+func (s Secret) GoString() string {
+	return REDACTED
+}
+
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(REDACTED)
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(REDACTED), nil
+}
+
+// Reveal returns the value of the secret, only to be sent to birdeye.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// Redact replaces every occurrence of the secret in str.
+func (s Secret) Redact(str string) string {
+	if s == "" {
+		return str
+	}
+	return strings.ReplaceAll(str, string(s), REDACTED)
+}
+
+// RedactURL returns u with the secret and the x-api-key query parameter redacted.
+func (s Secret) RedactURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.RawQuery != "" {
+		q := parsed.Query()
+		if q.Has("x-api-key") {
+			q.Set("x-api-key", REDACTED)
+			parsed.RawQuery = q.Encode()
+			u = parsed.String()
+		}
+	}
+	return s.Redact(u)
+}
+
+// sensitiveHeaders are redacted whatever their value.
+var sensitiveHeaders = []string{"X-Api-Key", "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// RedactHeader returns a copy of h with the secret and the credential headers redacted.
+func (s Secret) RedactHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		out[k] = make([]string, len(vs))
+		for i, v := range vs {
+			out[k][i] = s.Redact(v)
+		}
+	}
+	for _, k := range sensitiveHeaders {
+		if vs := out.Values(k); len(vs) > 0 {
+			out.Set(k, REDACTED)
+		}
+	}
+	return out
+}
+
+// RedactError returns err with the secret redacted from its message,
+// errors.Is and errors.As still see the original error.
+func (s Secret) RedactError(err error) error {
+	if err == nil || s == "" {
+		return err
+	}
+	msg := err.Error()
+	if redacted := s.Redact(msg); redacted != msg {
+		return &redactedError{msg: redacted, err: err}
+	}
+	return err
+}
+
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactLogger returns a logger redacting secret from every message and attribute logged through it.
+func redactLogger(logger *slog.Logger, secret Secret) *slog.Logger {
+	if h, ok := logger.Handler().(*redactHandler); ok && h.secret == secret {
+		return logger
+	}
+	return slog.New(&redactHandler{h: logger.Handler(), secret: secret})
+}
+
+// redactHandler redacts a secret from the records it passes on to h.
+type redactHandler struct {
+	h      slog.Handler
+	secret Secret
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.secret.Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.h.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactAttr(a)
+	}
+	return &redactHandler{h: h.h.WithAttrs(redacted), secret: h.secret}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{h: h.h.WithGroup(name), secret: h.secret}
+}
+
+func (h *redactHandler) redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.secret.Redact(v.String()))
+	case slog.KindGroup:
+		attrs := v.Group()
+		redacted := make([]slog.Attr, len(attrs))
+		for i, ga := range attrs {
+			redacted[i] = h.redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, h.secret.Redact(x.Error()))
+		case *url.URL:
+			return slog.String(a.Key, h.secret.RedactURL(x.String()))
+		case http.Header:
+			return slog.Any(a.Key, h.secret.RedactHeader(x))
+		case []byte:
+			return slog.String(a.Key, h.secret.Redact(string(x)))
+		default:
+			// other values keep their type unless their text contains the secret
+			str := fmt.Sprintf("%+v", x)
+			if redacted := h.secret.Redact(str); redacted != str {
+				return slog.String(a.Key, redacted)
+			}
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
@@ -0,0 +1,85 @@
+package gobe_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dwdwow/gobe"
+)
+
+const testSecretKey = "secret-api-key-0123456789"
+
+func TestSecret(t *testing.T) {
+	s := gobe.Secret(testSecretKey)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%v %s %q %x %#v %+v", s, s, s, s, s, struct{ Key gobe.Secret }{s})
+	b, err := json.Marshal(map[string]gobe.Secret{"key": s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(b)
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("msg", "key", s)
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("msg", "key", s)
+	if strings.Contains(buf.String(), testSecretKey) || strings.Contains(buf.String(), fmt.Sprintf("%x", testSecretKey)) {
+		t.Fatalf("secret printed: %s", buf.String())
+	}
+	if s.Reveal() != testSecretKey {
+		t.Fatal("Reveal does not return the secret")
+	}
+	if u := s.RedactURL("wss://host/socket/solana?x-api-key=other&a=1"); strings.Contains(u, "other") {
+		t.Fatalf("x-api-key not redacted from %s", u)
+	}
+}
+
+func TestClientRedaction(t *testing.T) {
+	errTransport := errors.New("transport failed")
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`{"success":true,"data":["solana"]}`))
+	}))
+	defer srv.Close()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	clt := gobe.NewClient(testSecretKey, nil, gobe.WithBaseURL(srv.URL), gobe.WithLogger(logger))
+	if _, err := clt.SupportedNetworksCtx(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("x-api-key") != testSecretKey {
+		t.Fatal("api key not sent in header")
+	}
+
+	// a transport error echoing the key
+	clt = gobe.NewClient(testSecretKey, nil, gobe.WithBaseURL(srv.URL), gobe.WithLogger(logger),
+		gobe.WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, fmt.Errorf("%w with key %s", errTransport, r.Header.Get("x-api-key"))
+		})))
+	_, err := clt.SupportedNetworksCtx(context.Background())
+	if !errors.Is(err, errTransport) {
+		t.Fatalf("expected transport error, got %v", err)
+	}
+	if strings.Contains(err.Error(), testSecretKey) {
+		t.Fatalf("api key in error: %v", err)
+	}
+	if !strings.Contains(logs.String(), `"birdeye: request"`) || !strings.Contains(logs.String(), `"birdeye: request failed"`) {
+		t.Fatalf("requests not logged: %s", logs.String())
+	}
+	if strings.Contains(logs.String(), testSecretKey) {
+		t.Fatalf("api key logged: %s", logs.String())
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
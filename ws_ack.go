@@ -0,0 +1,226 @@
+package gobe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultWsAckTimeout is how long a subscription stays pending without messages or errors.
+const DefaultWsAckTimeout = 3 * time.Second
+
+// WsSubStatus is the state of a subscription request on the server.
+//
+// Birdeye does not acknowledge subscriptions, so a subscription becomes active
+// on its first message or when no ERROR message arrived within the ack timeout.
+type WsSubStatus string
+
+const (
+	// WS_SUB_PENDING is a subscription sent, or resent after reconnecting, but not acknowledged yet
+	WS_SUB_PENDING WsSubStatus = "pending"
+	// WS_SUB_ACTIVE is a subscription that received a message or no error within the ack timeout
+	WS_SUB_ACTIVE WsSubStatus = "active"
+	// WS_SUB_REJECTED is a subscription the server answered with an ERROR message,
+	// it is no longer resent after reconnecting
+	WS_SUB_REJECTED WsSubStatus = "rejected"
+)
+
+// WsServerError is an ERROR message sent by birdeye.
+type WsServerError struct {
+	Message string
+	// Data is the raw data of the ERROR message
+	Data json.RawMessage
+	// Request is the subscription the error was correlated to, nil if none was pending
+	Request any
+}
+
+func (e *WsServerError) Error() string {
+	if e.Request != nil {
+		if r, ok := e.Request.(WsSubRequest); ok {
+			return fmt.Sprintf("birdeye: websocket error for %s: %s", r.SubType(), e.Message)
+		}
+	}
+	return "birdeye: websocket error: " + e.Message
+}
+
+// WithWsAckTimeout sets how long a subscription stays pending, default DefaultWsAckTimeout.
+func WithWsAckTimeout(d time.Duration) WsOption {
+	return func(c *WsClient) {
+		c.ackTimeout = d
+	}
+}
+
+// WithSubAck makes Subscribe wait until the subscription is active,
+// returning the server error if it is rejected.
+func WithSubAck() WsSubOption {
+	return func(c *wsSubConfig) {
+		c.ack = true
+	}
+}
+
+// Errors receives the ERROR messages of the server as *WsServerError,
+// including those correlated to a subscription. Errors are dropped if the channel is full.
+// The channel is closed by Close.
+func (c *WsClient) Errors() <-chan error {
+	return c.errs
+}
+
+// pend marks the entry pending after it was sent, muRW must be held.
+// An entry sent again while pending wakes the waiters of the previous round, which wait for the new one.
+func (c *WsClient) pend(e *wsSubEntry) {
+	if e.status == WS_SUB_PENDING && e.settled != nil {
+		c.pending.Add(-1)
+		close(e.settled)
+	}
+	e.status = WS_SUB_PENDING
+	e.err = nil
+	e.settled = make(chan struct{})
+	e.round++
+	c.pending.Add(1)
+	round := e.round
+	time.AfterFunc(c.ackTimeout, func() {
+		c.muRW.Lock()
+		defer c.muRW.Unlock()
+		if e.round == round {
+			c.settle(e, WS_SUB_ACTIVE, nil)
+		}
+	})
+}
+
+// settle resolves a pending entry, muRW must be held.
+func (c *WsClient) settle(e *wsSubEntry, status WsSubStatus, err error) {
+	if e.status != WS_SUB_PENDING {
+		return
+	}
+	c.pending.Add(-1)
+	e.status = status
+	e.err = err
+	close(e.settled)
+}
+
+// ackData activates the pending subscriptions producing d.
+func (c *WsClient) ackData(t WsDataType, d any) {
+	if c.pending.Load() == 0 {
+		return
+	}
+	c.muRW.Lock()
+	defer c.muRW.Unlock()
+	for _, e := range c.subs {
+		if e.status == WS_SUB_PENDING && !e.timeoutAck && wsSubDataTypes[e.subType] == t && (e.match == nil || e.match(d)) {
+			c.settle(e, WS_SUB_ACTIVE, nil)
+		}
+	}
+}
+
+// handleError correlates an ERROR message to a pending subscription and publishes it.
+// The subscription mentioned by the error is preferred, else the oldest pending one,
+// birdeye answers messages in the order they are sent.
+// The rejected entry stays until every holder released it with WsUnsub.
+func (c *WsClient) handleError(b []byte) {
+	serverErr := &WsServerError{Message: wsErrorMessage(b), Data: append(json.RawMessage(nil), b...)}
+	c.muRW.Lock()
+	var found *wsSubEntry
+	for _, e := range c.subs {
+		if e.status != WS_SUB_PENDING {
+			continue
+		}
+		if found == nil {
+			found = e
+		}
+		if e.mentionedBy(string(b)) {
+			found = e
+			break
+		}
+	}
+	if found != nil {
+		serverErr.Request = found.req
+		c.settle(found, WS_SUB_REJECTED, serverErr)
+	}
+	c.muRW.Unlock()
+	c.logger.Error("birdeye: error message", "data", string(b), "request", serverErr.Request)
+	select {
+	case c.errs <- serverErr:
+	default:
+		c.logger.Warn("birdeye: error channel is full, dropping error")
+	}
+}
+
+// wsErrorMessage extracts the message of an ERROR data, which may be a string or an object.
+func wsErrorMessage(b []byte) string {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return s
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err == nil {
+		for _, k := range []string{"message", "msg", "error"} {
+			if s, ok := m[k].(string); ok {
+				return s
+			}
+		}
+	}
+	return string(b)
+}
+
+// mentionedBy reports whether an error message contains an address or query of the subscription.
+func (e *wsSubEntry) mentionedBy(msg string) bool {
+	var frame struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(e.key), &frame); err != nil {
+		return false
+	}
+	for k, v := range frame.Data {
+		s, ok := v.(string)
+		// short values like chart types and currencies are shared by many subscriptions
+		if !ok || k == "queryType" || len(s) < 8 {
+			continue
+		}
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the state of the subscription on the server.
+func (s *Subscription[T]) Status() WsSubStatus {
+	s.c.muRW.Lock()
+	defer s.c.muRW.Unlock()
+	return s.entry.status
+}
+
+// Err returns the server error of a rejected subscription, nil otherwise.
+func (s *Subscription[T]) Err() error {
+	s.c.muRW.Lock()
+	defer s.c.muRW.Unlock()
+	return s.entry.err
+}
+
+// Wait blocks until the subscription is no longer pending or ctx is done,
+// returning the server error if it was rejected.
+func (s *Subscription[T]) Wait(ctx context.Context) error {
+	return s.c.waitSettled(ctx, s.entry)
+}
+
+// waitSettled blocks until e is no longer pending or ctx is done, returning the server error if it was rejected.
+func (c *WsClient) waitSettled(ctx context.Context, e *wsSubEntry) error {
+	for {
+		c.muRW.Lock()
+		status, settled, err := e.status, e.settled, e.err
+		c.muRW.Unlock()
+		if status != WS_SUB_PENDING {
+			return err
+		}
+		// settled is also closed when the entry is sent again, the status is then checked again
+		select {
+		case <-settled:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.ctx.Done():
+			return ErrWsClosed
+		}
+	}
+}
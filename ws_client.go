@@ -1,13 +1,17 @@
 package gobe
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -61,11 +65,42 @@ const (
 	WS_CURRENCY_PAIR WsCurrency = "pair"
 )
 
+// wsDataTypes creates the data struct of every data message type.
+var wsDataTypes = map[WsDataType]func() any{
+	WS_PRICE_DATA:             func() any { return &WsPriceData{} },
+	WS_TXS_DATA:               func() any { return &WsTxsData{} },
+	WS_BASE_QUOTE_PRICE_DATA:  func() any { return &WsBaseQuotePriceData{} },
+	WS_TOKEN_NEW_LISTING_DATA: func() any { return &WsTokenNewListingData{} },
+	WS_NEW_PAIR_DATA:          func() any { return &WsNewPairData{} },
+	WS_TXS_LARGE_TRADE_DATA:   func() any { return &WsLargeTradeTxsData{} },
+	WS_WALLET_TXS_DATA:        func() any { return &WsWalletTxsData{} },
+}
+
+// wsSubDataTypes maps a subscription type to the type of the messages it produces.
+var wsSubDataTypes = map[WsSubType]WsDataType{
+	SUBSCRIBE_PRICE:             WS_PRICE_DATA,
+	SUBSCRIBE_TXS:               WS_TXS_DATA,
+	SUBSCRIBE_BASE_QUOTE_PRICE:  WS_BASE_QUOTE_PRICE_DATA,
+	SUBSCRIBE_TOKEN_NEW_LISTING: WS_TOKEN_NEW_LISTING_DATA,
+	SUBSCRIBE_NEW_PAIR:          WS_NEW_PAIR_DATA,
+	SUBSCRIBE_LARGE_TRADE_TXS:   WS_TXS_LARGE_TRADE_DATA,
+	SUBSCRIBE_WALLET_TXS:        WS_WALLET_TXS_DATA,
+}
+
+// WsSubRequest is a subscription message, e.g. WsSubData[WsPriceSubData] or WsLargeTradeTxsSubData.
+type WsSubRequest interface {
+	SubType() WsSubType
+}
+
 type WsSubData[D any] struct {
 	Type WsSubType `json:"type"`
 	Data D         `json:"data"`
 }
 
+func (d WsSubData[D]) SubType() WsSubType {
+	return d.Type
+}
+
 type WsComplexSubData struct {
 	QueryType WsQueryType `json:"queryType"`
 	Query     string      `json:"query"`
@@ -110,6 +145,8 @@ type WsPriceData struct {
 	Symbol string `json:"symbol" bson:"symbol"`
 	// Token/Pair address
 	Address string `json:"address" bson:"address"`
+	// Backfilled is set on messages recovered via REST after reconnecting, see WithWsRecovery
+	Backfilled bool `json:"-" bson:"backfilled,omitempty"`
 }
 
 type WsTxsSubData struct {
@@ -120,6 +157,8 @@ type WsTxsSubData struct {
 	Address string `json:"address,omitempty" bson:"address,omitempty"`
 	// Pair address
 	PairAddress string `json:"pairAddress,omitempty" bson:"pairAddress,omitempty"`
+	// TxsType filters the trades ("swap", "add", "remove", "all", default: "swap")
+	TxsType TxType `json:"txsType,omitempty" bson:"txsType,omitempty"`
 }
 
 func (d WsTxsSubData) Query() string {
@@ -177,6 +216,8 @@ type WsTxsData struct {
 	From WsTxTokenInfo `json:"from" bson:"from"`
 	// To token info
 	To WsTxTokenInfo `json:"to" bson:"to"`
+	// Backfilled is set on messages recovered via REST after reconnecting, see WithWsRecovery
+	Backfilled bool `json:"-" bson:"backfilled,omitempty"`
 }
 
 type WsBaseQuotePriceSubData struct {
@@ -209,6 +250,8 @@ type WsBaseQuotePriceData struct {
 	BaseAddress string `json:"baseAddress" bson:"baseAddress"`
 	// Quote token address
 	QuoteAddress string `json:"quoteAddress" bson:"quoteAddress"`
+	// Backfilled is set on messages recovered via REST after reconnecting, see WithWsRecovery
+	Backfilled bool `json:"-" bson:"backfilled,omitempty"`
 }
 
 // WsTokenNewListingSubData represents subscription data for new token listing notifications
@@ -296,6 +339,10 @@ type WsLargeTradeTxsSubData struct {
 	MaxVolume float64 `json:"max_volume,omitempty" bson:"max_volume,omitempty"`
 }
 
+func (d WsLargeTradeTxsSubData) SubType() WsSubType {
+	return SUBSCRIBE_LARGE_TRADE_TXS
+}
+
 type WsLargeTradeTxsTokenInfo struct {
 	// Token address
 	Address string `json:"address" bson:"address"`
@@ -377,89 +424,463 @@ type WsWalletTxsData struct {
 	Quote WsWalletTxsTokenInfo `json:"quote" bson:"quote"`
 }
 
+const (
+	// DefaultWsWelcomeTimeout is how long Start waits for the WELCOME message.
+	DefaultWsWelcomeTimeout = 10 * time.Second
+	// DefaultWsPingInterval is how often the client pings the server.
+	DefaultWsPingInterval = 20 * time.Second
+	// DefaultWsPongWait is how long the connection may stay silent, pongs included, before it is considered dead.
+	DefaultWsPongWait = 60 * time.Second
+)
+
+// WsOption configures a WsClient created by NewWsClient.
+type WsOption func(*WsClient)
+
+// WithWsWelcomeTimeout sets how long Start waits for the WELCOME message, default DefaultWsWelcomeTimeout.
+func WithWsWelcomeTimeout(d time.Duration) WsOption {
+	return func(c *WsClient) {
+		c.welcomeTimeout = d
+	}
+}
+
+// WithWsHeartbeat sets the ping interval and how long the connection may stay silent,
+// the connection is reconnected if nothing, not even a pong, is read within pongWait.
+// A pingInterval <= 0 disables pings and read deadlines.
+func WithWsHeartbeat(pingInterval, pongWait time.Duration) WsOption {
+	return func(c *WsClient) {
+		c.pingInterval = pingInterval
+		c.pongWait = pongWait
+	}
+}
+
+// WithWsStaleTimeout reconnects when no data message arrives for d while at least one subscription is active.
+// It catches streams the server silently stopped while still answering pings, default 0 (disabled).
+func WithWsStaleTimeout(d time.Duration) WsOption {
+	return func(c *WsClient) {
+		c.staleTimeout = d
+	}
+}
+
+var (
+	// ErrWsClosed is returned by Err after Close was called.
+	ErrWsClosed = errors.New("birdeye: websocket client closed")
+	// ErrWsNotConnected is returned when writing before Start or while reconnecting.
+	ErrWsNotConnected = errors.New("birdeye: websocket not connected")
+)
+
 type WsClient struct {
-	url string
-	ws  *websocket.Conn
+	chain string
+	url   string
+	ws    *websocket.Conn
+
+	apiKey Secret
+	// keyInHeader sends the api key in a header instead of the url
+	keyInHeader bool
+	baseURL     string
+	dialer      websocket.Dialer
+	headers     http.Header
+	readLimit   int64
 
 	muReConn sync.RWMutex
 
 	muSubers sync.RWMutex
-	subers   map[WsDataType][]chan any
+	subers   map[WsDataType][]wsSuber
 
 	muRW sync.Mutex
 
-	subs []any
+	subs []*wsSubEntry
+
+	chWelcome      chan struct{}
+	welcomeTimeout time.Duration
+
+	pingInterval time.Duration
+	pongWait     time.Duration
+	staleTimeout time.Duration
+	// lastData is the unix nano time of the last data message, connection or new subscription
+	lastData atomic.Int64
+
+	backoff WsBackoff
+
+	// dispatch is the default buffer and overflow policy of subscribers
+	dispatch wsSubConfig
+	dropped  atomic.Uint64
 
-	chWelcome chan struct{}
+	ackTimeout time.Duration
+	// unmarshal decodes the data of messages
+	unmarshal WsUnmarshalFunc
+	// pending is the number of pending subscription entries
+	pending atomic.Int64
+	errs    chan error
+	muState sync.Mutex
+	onState func(WsStateEvent)
+
+	// rest backfills the messages missed while reconnecting, nil disables recovery
+	rest *Client
+
+	recorder     *WsRecorder
+	recordFailed atomic.Bool
+	// replay is set on clients created by NewWsReplayClient, muReplay serializes Replay calls
+	replay   bool
+	muReplay sync.Mutex
+
+	// ctx is canceled by Close, stopping connecting and reconnecting
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	done      chan struct{}
+	muErr     sync.Mutex
+	err       error
 
 	logger *slog.Logger
 }
 
-func NewWsClient(chain, apiKey string, logger *slog.Logger) *WsClient {
+// NewWsClient creates a client of the websocket of chain, connect it with Start.
+// The api key is sent in the url like birdeye documents it, unless WithWsAPIKeyHeader is set,
+// and is redacted from every log and error of the client.
+func NewWsClient(chain, apiKey string, logger *slog.Logger, opts ...WsOption) *WsClient {
+	if apiKey == "" {
+		panic("birdeye: api key is required")
+	}
+	return newWsClient(chain, apiKey, logger, opts...)
+}
+
+func newWsClient(chain, apiKey string, logger *slog.Logger, opts ...WsOption) *WsClient {
 	if logger == nil {
 		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &WsClient{
+		chain:          chain,
+		apiKey:         Secret(apiKey),
+		unmarshal:      json.Unmarshal,
+		baseURL:        WS_BASE_URL,
+		dialer:         *websocket.DefaultDialer,
+		headers:        defaultWsHeaders(),
+		subers:         make(map[WsDataType][]wsSuber),
+		chWelcome:      make(chan struct{}, 1),
+		welcomeTimeout: DefaultWsWelcomeTimeout,
+		pingInterval:   DefaultWsPingInterval,
+		pongWait:       DefaultWsPongWait,
+		backoff:        DefaultWsBackoff,
+		ackTimeout:     DefaultWsAckTimeout,
+		errs:           make(chan error, 100),
+		dispatch:       wsSubConfig{bufferSize: DefaultWsSubBufferSize, overflow: OVERFLOW_DROP_NEWEST},
+		ctx:            ctx,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+		logger:         logger,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.url = fmt.Sprintf("%s/%s", c.baseURL, chain)
 	if apiKey == "" {
-		panic("birdeye: api key is required")
+		return c
+	}
+	c.logger = redactLogger(c.logger, c.apiKey)
+	if c.keyInHeader {
+		c.headers.Set("x-api-key", c.apiKey.Reveal())
+	} else {
+		c.url += "?x-api-key=" + url.QueryEscape(c.apiKey.Reveal())
 	}
-	url := fmt.Sprintf("wss://public-api.birdeye.so/socket/%s?x-api-key=%s", chain, apiKey)
-	return &WsClient{url: url, subers: make(map[WsDataType][]chan any), chWelcome: make(chan struct{}), logger: logger}
+	return c
 }
 
 func (c *WsClient) Start() error {
-	headers := http.Header{}
-	headers.Add("Origin", "ws://public-api.birdeye.so")
-	headers.Add("Sec-WebSocket-Origin", "ws://public-api.birdeye.so")
-	headers.Add("Sec-WebSocket-Protocol", "echo-protocol")
-	conn, reps, err := websocket.DefaultDialer.Dial(c.url, headers)
+	return c.StartCtx(context.Background())
+}
+
+// StartCtx connects and waits for the WELCOME message,
+// giving up when ctx is done or no WELCOME arrives within the welcome timeout.
+// The client reconnects by itself until Close is called.
+func (c *WsClient) StartCtx(ctx context.Context) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	if c.replay {
+		return nil
+	}
+	return c.connect(ctx, 0)
+}
+
+// connect dials and waits for the WELCOME message, attempt is only reported in state events.
+func (c *WsClient) connect(ctx context.Context, attempt int) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(c.ctx, cancel)
+	defer stop()
+
+	select {
+	case <-c.chWelcome:
+	default:
+	}
+
+	c.emitState(WS_STATE_CONNECTING, attempt, nil)
+	conn, reps, err := c.dialer.DialContext(ctx, c.url, c.headers.Clone())
 	if err != nil {
-		return fmt.Errorf("birdeye: failed to connect to websocket: %w, http status code: %d", err, reps.StatusCode)
+		err = c.apiKey.RedactError(err)
+		if reps != nil {
+			err = fmt.Errorf("birdeye: failed to connect to websocket: %w, http status code: %d", err, reps.StatusCode)
+		} else {
+			err = fmt.Errorf("birdeye: failed to connect to websocket: %w", err)
+		}
+		c.emitState(WS_STATE_DISCONNECTED, attempt, err)
+		return err
+	}
+	c.muRW.Lock()
+	if c.ctx.Err() != nil {
+		c.muRW.Unlock()
+		conn.Close()
+		c.emitState(WS_STATE_DISCONNECTED, attempt, ErrWsClosed)
+		return ErrWsClosed
 	}
 	c.ws = conn
-	go c.waiter()
-	<-c.chWelcome
-	return nil
+	c.wg.Add(2)
+	c.muRW.Unlock()
+	c.touch()
+	if c.readLimit > 0 {
+		conn.SetReadLimit(c.readLimit)
+	}
+	if c.pingInterval > 0 {
+		conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		})
+	}
+	c.emitState(WS_STATE_CONNECTED, attempt, nil)
+	connDone := make(chan struct{})
+	stale := new(atomic.Bool)
+	go c.waiter(conn, connDone, stale)
+	go c.heartbeat(conn, connDone, stale)
+
+	timer := time.NewTimer(c.welcomeTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.chWelcome:
+		c.emitState(WS_STATE_WELCOMED, attempt, nil)
+		return nil
+	case <-timer.C:
+		err = fmt.Errorf("birdeye: no welcome message in %s", c.welcomeTimeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	c.muRW.Lock()
+	if c.ws == conn {
+		c.ws = nil
+	}
+	c.muRW.Unlock()
+	conn.Close()
+	c.emitState(WS_STATE_DISCONNECTED, attempt, err)
+	return err
+}
+
+// release clears the connection in use if it is conn, reporting whether it was.
+func (c *WsClient) release(conn *websocket.Conn) bool {
+	c.muRW.Lock()
+	defer c.muRW.Unlock()
+	if c.ws != conn {
+		return false
+	}
+	c.ws = nil
+	return true
+}
+
+// writeJSON writes v to the current connection, muRW must be held.
+func (c *WsClient) writeJSON(v any) error {
+	if c.ctx.Err() != nil {
+		return ErrWsClosed
+	}
+	if c.replay {
+		// messages come from Replay, there is no server to write to
+		return nil
+	}
+	if c.ws == nil {
+		return ErrWsNotConnected
+	}
+	return c.ws.WriteJSON(v)
 }
 
+// reConn reconnects until it succeeds, Close is called or the backoff gives up.
+// Giving up stops the client with ErrWsGaveUp.
 func (c *WsClient) reConn() {
 	if !c.muReConn.TryLock() {
 		return
 	}
 	defer c.muReConn.Unlock()
-	for {
-		c.logger.Info("birdeye: retrying to connect to websocket...")
-		err := c.Start()
-		if err != nil {
-			c.logger.Error("birdeye: failed to connect to websocket, retrying...", "error", err)
-			time.Sleep(5 * time.Second)
+	lost := time.Now()
+	for attempt := 1; c.ctx.Err() == nil; attempt++ {
+		c.logger.Info("birdeye: retrying to connect to websocket...", "attempt", attempt)
+		err := c.connect(c.ctx, attempt)
+		if err == nil {
+			c.logger.Info("birdeye: reconnected to websocket")
+			c.resubscribe(attempt)
+			c.recoverGaps(lost)
+			return
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		delay, ok := c.backoff.Next(attempt, time.Since(lost))
+		if !ok {
+			err = fmt.Errorf("%w after %d attempts: %w", ErrWsGaveUp, attempt, err)
+			c.logger.Error("birdeye: gave up reconnecting to websocket", "error", err)
+			c.emitState(WS_STATE_GAVE_UP, attempt, err)
+			c.stop(err)
+			// Close waits for this goroutine
+			go c.Close()
+			return
+		}
+		c.logger.Error("birdeye: failed to connect to websocket, retrying...", "error", err, "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-c.ctx.Done():
+		}
+	}
+}
+
+// resubscribe sends every subscription again on the new connection.
+func (c *WsClient) resubscribe(attempt int) {
+	c.muRW.Lock()
+	defer c.muRW.Unlock()
+	var errs []error
+	for _, sub := range c.subs {
+		if sub.status == WS_SUB_REJECTED {
 			continue
 		}
-		c.logger.Info("birdeye: reconnected to websocket")
+		c.logger.Info("birdeye: resubscribing", "sub", sub.key)
+		if err := c.writeJSON(sub.req); err != nil {
+			c.logger.Error("birdeye: failed to resubscribe", "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		c.pend(sub)
+	}
+	c.emitState(WS_STATE_RESUBSCRIBED, attempt, errors.Join(errs...))
+}
+
+// Close stops the client for good: it closes the connection, stops reconnecting
+// and closes every subscriber channel, messages already buffered in them can still be read.
+// Close returns once all goroutines of the client have exited.
+func (c *WsClient) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.cancel()
 		c.muRW.Lock()
-		defer c.muRW.Unlock()
-		for _, sub := range c.subs {
-			c.logger.Info("birdeye: resubscribing", "sub", sub)
-			err := c.WsSub(sub)
-			if err != nil {
-				c.logger.Error("birdeye: failed to resubscribe", "error", err)
+		connected := c.ws != nil
+		if connected {
+			err = c.ws.Close()
+		}
+		c.muRW.Unlock()
+		if connected {
+			c.emitState(WS_STATE_DISCONNECTED, 0, ErrWsClosed)
+		}
+		c.wg.Wait()
+		c.muSubers.Lock()
+		for t, subers := range c.subers {
+			for _, suber := range subers {
+				suber.close()
 			}
+			delete(c.subers, t)
 		}
+		c.muSubers.Unlock()
+		close(c.errs)
+		c.stop(ErrWsClosed)
+	})
+	return err
+}
+
+// stop marks the client as permanently stopped with err.
+func (c *WsClient) stop(err error) {
+	c.muErr.Lock()
+	defer c.muErr.Unlock()
+	if c.err != nil {
 		return
 	}
+	c.err = err
+	close(c.done)
 }
 
-func (c *WsClient) Close() error {
-	return c.ws.Close()
+// Done is closed when the client has permanently stopped.
+func (c *WsClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client stopped, nil while it is running.
+func (c *WsClient) Err() error {
+	c.muErr.Lock()
+	defer c.muErr.Unlock()
+	return c.err
+}
+
+// touch marks now as the time of the last data message.
+func (c *WsClient) touch() {
+	c.lastData.Store(time.Now().UnixNano())
+}
+
+// heartbeat pings the server and closes conn when the subscribed streams are stale,
+// so the waiter of conn fails to read and reconnects.
+func (c *WsClient) heartbeat(conn *websocket.Conn, connDone <-chan struct{}, stale *atomic.Bool) {
+	defer c.wg.Done()
+	interval := c.pingInterval
+	if c.staleTimeout > 0 && (interval <= 0 || c.staleTimeout/2 < interval) {
+		interval = c.staleTimeout / 2
+	}
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastPing := time.Now()
+	for {
+		select {
+		case <-connDone:
+			return
+		case <-ticker.C:
+		}
+		if c.pingInterval > 0 && time.Since(lastPing) >= c.pingInterval {
+			lastPing = time.Now()
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.pingInterval)); err != nil {
+				c.logger.Error("birdeye: failed to ping websocket", "error", err)
+			}
+		}
+		if c.staleTimeout > 0 && c.hasSubs() {
+			if idle := time.Since(time.Unix(0, c.lastData.Load())); idle > c.staleTimeout {
+				c.logger.Error("birdeye: websocket stream is stale, reconnecting", "idle", idle)
+				stale.Store(true)
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (c *WsClient) hasSubs() bool {
+	c.muRW.Lock()
+	defer c.muRW.Unlock()
+	return len(c.subs) > 0
 }
 
-func (c *WsClient) waiter() {
+func (c *WsClient) waiter(conn *websocket.Conn, connDone chan<- struct{}, stale *atomic.Bool) {
+	defer c.wg.Done()
 	for {
-		t, b, err := c.ws.ReadMessage()
+		t, b, err := conn.ReadMessage()
 		if err != nil {
+			close(connDone)
+			if c.ctx.Err() != nil || !c.release(conn) {
+				return
+			}
+			if stale.Load() {
+				err = ErrWsStale
+			}
 			c.logger.Error("birdeye: websocket read error", "error", err)
+			c.emitState(WS_STATE_DISCONNECTED, 0, err)
 			c.reConn()
 			return
 		}
+		if c.pingInterval > 0 {
+			conn.SetReadDeadline(time.Now().Add(c.pongWait))
+		}
 		switch t {
 		case websocket.BinaryMessage:
 			c.logger.Info("birdeye: websocket binary message", "data", string(b))
@@ -468,107 +889,242 @@ func (c *WsClient) waiter() {
 		case websocket.PongMessage:
 			c.logger.Info("birdeye: websocket pong message", "data", string(b))
 		case websocket.TextMessage:
-			go c.msgHandler(b)
+			if c.recorder != nil {
+				c.record(b)
+			}
+			c.touch()
+			// handled in the read loop so messages are delivered in the order they arrive
+			c.msgHandler(b)
 		case websocket.CloseMessage:
 			c.logger.Info("birdeye: websocket close message", "data", string(b))
 		}
 	}
 }
 
+// msgHandler decodes a message and delivers it to the subscribers of its type.
+// The envelope is decoded once, its data is decoded straight into a pooled struct
+// which is reused once every subscriber returned.
 func (c *WsClient) msgHandler(b []byte) {
-	d := map[string]any{}
-	err := json.Unmarshal(b, &d)
-	if err != nil {
+	var msg wsEnvelope
+	if err := json.Unmarshal(b, &msg); err != nil {
 		c.logger.Error("birdeye: failed to unmarshal message", "error", err)
 		return
 	}
-	t, ok := d["type"].(string)
-	if !ok {
-		c.logger.Error("birdeye: message type is not string", "data", string(b))
-		return
-	}
-	b, err = json.Marshal(d["data"])
-	if err != nil {
-		c.logger.Error("birdeye: failed to marshal data", "error", err)
+	if msg.Type == "" {
+		c.logger.Error("birdeye: message has no type", "data", string(b))
 		return
 	}
-	var dd any
-	switch WsDataType(t) {
+	switch msg.Type {
 	case WS_WELCOME_DATA:
-		c.chWelcome <- struct{}{}
-		c.logger.Info("birdeye: welcome message", "data", string(b))
+		select {
+		case c.chWelcome <- struct{}{}:
+		default:
+		}
+		c.logger.Info("birdeye: welcome message", "data", string(msg.Data))
 		return
 	case WS_ERROR_DATA:
-		c.logger.Error("birdeye: error message", "data", string(b))
+		c.handleError(msg.Data)
 		return
-	case WS_PRICE_DATA:
-		dd = &WsPriceData{}
-	case WS_TXS_DATA:
-		dd = &WsTxsData{}
-	case WS_BASE_QUOTE_PRICE_DATA:
-		dd = &WsBaseQuotePriceData{}
-	case WS_TOKEN_NEW_LISTING_DATA:
-		dd = &WsTokenNewListingData{}
-	case WS_NEW_PAIR_DATA:
-		dd = &WsNewPairData{}
-	case WS_TXS_LARGE_TRADE_DATA:
-		dd = &WsLargeTradeTxsData{}
-	case WS_WALLET_TXS_DATA:
-		dd = &WsWalletTxsData{}
-	default:
-		c.logger.Error("birdeye: unknown message type", "type", t, "data", string(b))
+	}
+	codec, ok := wsCodecs[msg.Type]
+	if !ok {
+		c.logger.Error("birdeye: unknown message type", "type", msg.Type, "data", string(msg.Data))
 		return
 	}
-	err = json.Unmarshal(b, dd)
+	dd, err := codec.decode(c.unmarshal, msg.Data)
 	if err != nil {
 		c.logger.Error("birdeye: failed to unmarshal data", "error", err)
 		return
 	}
+	defer codec.release(dd)
+	c.ackData(msg.Type, dd)
+	// removeSuber never modifies the slice in place, so it can be used after unlocking
 	c.muSubers.RLock()
-	defer c.muSubers.RUnlock()
-	subers := c.subers[WsDataType(t)]
+	subers := c.subers[msg.Type]
+	c.muSubers.RUnlock()
 	for _, suber := range subers {
-		suber := suber
-		go func() {
-			timer := time.NewTimer(10 * time.Second)
-			defer timer.Stop()
-			select {
-			case suber <- dd:
-			case <-timer.C:
-				c.logger.Error("birdeye: failed to send data to suber", "type", t)
-			}
-		}()
+		suber.deliver(dd)
+	}
+}
+
+// wsSubEntry is an active subscription request, resent after reconnecting.
+type wsSubEntry struct {
+	// key is the json of req, identical requests share one entry
+	key     string
+	req     any
+	refs    int
+	subType WsSubType
+	// match filters the messages acknowledging the subscription, nil accepts all of its data type
+	match func(any) bool
+	// timeoutAck entries are not acknowledged by messages, only by the ack timeout without ERROR
+	timeoutAck bool
+
+	// status, err, settled and round are guarded by muRW, see pend
+	status WsSubStatus
+	err    error
+	// settled is closed when the entry stops being pending
+	settled chan struct{}
+	round   int
+}
+
+// wsSubKey returns the json of a subscription message and its type.
+func wsSubKey(d any) (string, WsSubType, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return "", "", fmt.Errorf("birdeye: marshal subscription: %w", err)
+	}
+	var head struct {
+		Type WsSubType `json:"type"`
+	}
+	if err := json.Unmarshal(b, &head); err != nil {
+		return "", "", fmt.Errorf("birdeye: subscription is not a json object: %w", err)
 	}
+	return string(b), head.Type, nil
 }
 
+// WsSub sends a subscription message, e.g. WsSubData[WsPriceSubData].
+// Identical subscriptions are reference counted and sent once,
+// every active subscription is sent again after reconnecting.
+// It does not wait for the server, rejections are published on Errors.
 func (c *WsClient) WsSub(d any) error {
+	_, err := c.wsSub(d)
+	return err
+}
+
+func (c *WsClient) wsSub(d any) (*wsSubEntry, error) {
+	return c.sendSub(d, false)
+}
+
+// sendSub sends d or adds a reference to its entry, see wsSubEntry.timeoutAck.
+func (c *WsClient) sendSub(d any, timeoutAck bool) (*wsSubEntry, error) {
+	key, t, err := wsSubKey(d)
+	if err != nil {
+		return nil, err
+	}
+	c.muRW.Lock()
+	defer c.muRW.Unlock()
+	for _, sub := range c.subs {
+		if sub.key != key {
+			continue
+		}
+		if sub.status == WS_SUB_REJECTED {
+			// subscribing again retries a rejected request, for every holder
+			if err := c.writeJSON(d); err != nil {
+				return nil, err
+			}
+			c.pend(sub)
+		}
+		sub.refs++
+		return sub, nil
+	}
+	if err := c.writeJSON(d); err != nil {
+		return nil, err
+	}
+	entry := &wsSubEntry{key: key, req: d, refs: 1, subType: t, timeoutAck: timeoutAck}
+	if r, ok := d.(WsSubRequest); ok {
+		entry.match = wsSubMatcher(r)
+	}
+	c.pend(entry)
+	c.subs = append(c.subs, entry)
+	c.touch()
+	return entry, nil
+}
+
+var wsUnsubTypes = map[WsSubType]WsSubType{
+	SUBSCRIBE_PRICE:             UNSUBSCRIBE_PRICE,
+	SUBSCRIBE_TXS:               UNSUBSCRIBE_TXS,
+	SUBSCRIBE_BASE_QUOTE_PRICE:  UNSUBSCRIBE_BASE_QUOTE_PRICE,
+	SUBSCRIBE_TOKEN_NEW_LISTING: UNSUBSCRIBE_TOKEN_NEW_LISTING,
+	SUBSCRIBE_NEW_PAIR:          UNSUBSCRIBE_NEW_PAIR,
+	SUBSCRIBE_LARGE_TRADE_TXS:   UNSUBSCRIBE_LARGE_TRADE_TXS,
+	SUBSCRIBE_WALLET_TXS:        UNSUBSCRIBE_WALLET_TXS,
+}
+
+// WsUnsub releases a subscription sent by WsSub.
+// When the last reference is released the matching UNSUBSCRIBE_* message, carrying the same data,
+// is sent and the subscription is no longer resent after reconnecting.
+// Rejected subscriptions are released the same way, without sending anything.
+func (c *WsClient) WsUnsub(d any) error {
+	key, t, err := wsSubKey(d)
+	if err != nil {
+		return err
+	}
+	unsubType, ok := wsUnsubTypes[t]
+	if !ok {
+		return fmt.Errorf("birdeye: can not unsubscribe %q", t)
+	}
 	c.muRW.Lock()
 	defer c.muRW.Unlock()
-	c.subs = append(c.subs, d)
-	return c.ws.WriteJSON(d)
+	for i, sub := range c.subs {
+		if sub.key != key {
+			continue
+		}
+		sub.refs--
+		if sub.refs > 0 {
+			return nil
+		}
+		c.subs = append(c.subs[:i:i], c.subs[i+1:]...)
+		if sub.status == WS_SUB_REJECTED {
+			// the server has nothing to unsubscribe
+			return nil
+		}
+		frame := map[string]json.RawMessage{}
+		if err := json.Unmarshal([]byte(key), &frame); err != nil {
+			return fmt.Errorf("birdeye: unmarshal subscription: %w", err)
+		}
+		frame["type"], _ = json.Marshal(unsubType)
+		if err := c.writeJSON(frame); err != nil && !errors.Is(err, ErrWsNotConnected) {
+			return err
+		}
+		// while reconnecting there is nothing to unsubscribe from, the new connection will not subscribe
+		return nil
+	}
+	return fmt.Errorf("birdeye: not subscribed: %s", key)
 }
 
+// NewDataChan returns a channel receiving every message of type t as a pointer, e.g. *WsPriceData.
+// Prefer the typed Subscribe* methods.
+// The buffer and overflow policy are the client defaults, see WithWsDispatch.
 func (c *WsClient) NewDataChan(t WsDataType) <-chan any {
+	// messages are pooled, the channel receives copies
+	suber := newChanSuber(c.suberEnv(), t, c.dispatch, nil, func(d any) (any, bool) { return wsCodecs[t].clone(d), true })
+	c.addSuber(suber)
+	return suber.ch
+}
+
+func (c *WsClient) addSuber(suber wsSuber) {
 	c.muSubers.Lock()
 	defer c.muSubers.Unlock()
-	ch := make(chan any, 100)
-	c.subers[t] = append(c.subers[t], ch)
-	return ch
-}
-
-// func (c *WsClient) Unsubscribe(t WsSubType, ch <-chan any) {
-// 	c.muSubers.Lock()
-// 	defer c.muSubers.Unlock()
-// 	for i, suber := range c.subers[t] {
-// 		if suber != ch {
-// 			continue
-// 		}
-// 		close(suber)
-// 		if i == len(c.subers[t])-1 {
-// 			c.subers[t] = c.subers[t][:i]
-// 		} else {
-// 			c.subers[t] = append(c.subers[t][:i], c.subers[t][i+1:]...)
-// 		}
-// 		break
-// 	}
-// }
+	t := suber.dataType()
+	c.subers[t] = append(c.subers[t], suber)
+}
+
+func (c *WsClient) removeSuber(suber wsSuber) {
+	c.muSubers.Lock()
+	defer c.muSubers.Unlock()
+	t := suber.dataType()
+	for i, s := range c.subers[t] {
+		if s == suber {
+			c.subers[t] = append(c.subers[t][:i:i], c.subers[t][i+1:]...)
+			break
+		}
+	}
+}
+
+// RemoveDataChan removes and closes a channel created by NewDataChan.
+func (c *WsClient) RemoveDataChan(ch <-chan any) {
+	c.muSubers.RLock()
+	var found wsSuber
+	for _, subers := range c.subers {
+		for _, suber := range subers {
+			if s, ok := suber.(*chanSuber[any]); ok && (<-chan any)(s.ch) == ch {
+				found = suber
+			}
+		}
+	}
+	c.muSubers.RUnlock()
+	if found != nil {
+		found.close()
+		c.removeSuber(found)
+	}
+}
@@ -0,0 +1,1281 @@
+package gobe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeWsServer is a local stand-in for the birdeye websocket.
+type fakeWsServer struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	// noWelcome disables the WELCOME message on connect
+	noWelcome bool
+
+	mu    sync.Mutex
+	conns []*websocket.Conn
+	// reject refuses new connections
+	reject bool
+	// header and query are the handshake request header and query of the latest connection
+	header http.Header
+	query  url.Values
+	// frames receives every frame sent by clients
+	frames chan map[string]any
+}
+
+func newFakeWsServer(t *testing.T) *fakeWsServer {
+	s := &fakeWsServer{t: t, frames: make(chan map[string]any, 100)}
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		reject := s.reject
+		s.mu.Unlock()
+		if reject {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.header = r.Header.Clone()
+		s.query = r.URL.Query()
+		noWelcome := s.noWelcome
+		s.mu.Unlock()
+		if !noWelcome {
+			s.send(conn, WS_WELCOME_DATA, nil)
+		}
+		for {
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			d := map[string]any{}
+			if err := json.Unmarshal(b, &d); err == nil {
+				s.frames <- d
+			}
+		}
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *fakeWsServer) url() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/socket"
+}
+
+func (s *fakeWsServer) send(conn *websocket.Conn, t WsDataType, data any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := conn.WriteJSON(map[string]any{"type": t, "data": data}); err != nil {
+		s.t.Log("fake ws server: write:", err)
+	}
+}
+
+// push sends a message to the latest connection.
+func (s *fakeWsServer) push(t WsDataType, data any) {
+	s.mu.Lock()
+	conn := s.conns[len(s.conns)-1]
+	s.mu.Unlock()
+	s.send(conn, t, data)
+}
+
+// drop closes every connection from the server side.
+func (s *fakeWsServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *fakeWsServer) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func (s *fakeWsServer) nextFrame(t *testing.T) map[string]any {
+	t.Helper()
+	select {
+	case d := <-s.frames:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame received")
+	}
+	return nil
+}
+
+func newTestWsClient(t *testing.T, s *fakeWsServer, opts ...WsOption) *WsClient {
+	c := NewWsClient(CHAIN_SOLANA, "test-key", slog.New(slog.NewTextHandler(io.Discard, nil)), append([]WsOption{WithWsBaseURL(s.url())}, opts...)...)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func recv[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+	return *new(T)
+}
+
+func TestWsSubscribePrice(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s)
+	defer c.Close()
+
+	sub, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-a", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := s.nextFrame(t)
+	if frame["type"] != string(SUBSCRIBE_PRICE) || frame["data"].(map[string]any)["address"] != "token-a" {
+		t.Fatalf("unexpected subscribe frame %v", frame)
+	}
+
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-b", Type: CHART_1m, C: 1})
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-a", Type: CHART_1m, C: 2})
+	if d := recv(t, sub.C); d.Address != "token-a" || d.C != 2 {
+		t.Fatalf("unexpected price %+v", d)
+	}
+
+	_, err = Subscribe[WsTxsData](context.Background(), c, WsSubData[WsPriceSubData]{Type: SUBSCRIBE_PRICE})
+	if err == nil {
+		t.Fatal("expected error for mismatched data type")
+	}
+}
+
+func TestWsUnsubscribe(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s)
+	defer c.Close()
+
+	req := WsComplexSubData{Query: JoinQuery(
+		WsPriceSubData{Address: "token-a", ChartType: CHART_1m, Currency: WS_CURRENCY_USD}.Query(),
+		WsPriceSubData{Address: "token-b", ChartType: CHART_1m, Currency: WS_CURRENCY_USD}.Query(),
+	)}
+	sub1, err := c.SubscribePriceComplex(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub2, err := c.SubscribePriceComplex(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame := s.nextFrame(t); frame["type"] != string(SUBSCRIBE_PRICE) {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	if len(c.subs) != 1 || c.subs[0].refs != 2 {
+		t.Fatalf("expected one shared subscription, got %+v", c.subs)
+	}
+
+	if err := sub1.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-sub1.C; ok {
+		t.Fatal("expected closed channel")
+	}
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-a", Type: CHART_1m})
+	recv(t, sub2.C)
+
+	if err := sub2.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	frame := s.nextFrame(t)
+	if frame["type"] != string(UNSUBSCRIBE_PRICE) || frame["data"].(map[string]any)["query"] != req.Query {
+		t.Fatalf("unexpected unsubscribe frame %v", frame)
+	}
+	if len(c.subs) != 0 {
+		t.Fatalf("expected no subscriptions, got %+v", c.subs)
+	}
+	if err := sub2.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWsStartWelcomeTimeout(t *testing.T) {
+	s := newFakeWsServer(t)
+	s.noWelcome = true
+	c := NewWsClient(CHAIN_SOLANA, "test-key", slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithWsBaseURL(s.url()), WithWsWelcomeTimeout(100*time.Millisecond))
+	defer c.Close()
+	if err := c.Start(); err == nil {
+		t.Fatal("expected welcome timeout")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c.welcomeTimeout = time.Minute
+	if err := c.StartCtx(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestWsClose(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s)
+
+	sub, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-a", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-a", Type: CHART_1m})
+	time.Sleep(100 * time.Millisecond)
+
+	if c.Err() != nil {
+		t.Fatalf("unexpected error before close: %v", c.Err())
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("done not closed")
+	}
+	if !errors.Is(c.Err(), ErrWsClosed) {
+		t.Fatalf("expected ErrWsClosed, got %v", c.Err())
+	}
+	// the buffered message is still readable, then the channel is closed
+	recv(t, sub.C)
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected closed channel")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n := s.connCount(); n != 1 {
+		t.Fatalf("expected no reconnection after close, got %d connections", n)
+	}
+	if err := c.Start(); !errors.Is(err, ErrWsClosed) {
+		t.Fatalf("expected ErrWsClosed on restart, got %v", err)
+	}
+}
+
+func TestWsStaleReconnect(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s, WithWsStaleTimeout(200*time.Millisecond))
+	defer c.Close()
+
+	// no subscription, an idle connection is not stale
+	time.Sleep(500 * time.Millisecond)
+	if n := s.connCount(); n != 1 {
+		t.Fatalf("expected 1 connection, got %d", n)
+	}
+
+	sub, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-a", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	// the stream stays silent, so the client reconnects and subscribes again
+	if frame := s.nextFrame(t); frame["type"] != string(SUBSCRIBE_PRICE) {
+		t.Fatalf("unexpected resubscribe frame %v", frame)
+	}
+	if n := s.connCount(); n < 2 {
+		t.Fatalf("expected a reconnection, got %d connections", n)
+	}
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-a", Type: CHART_1m})
+	recv(t, sub.C)
+}
+
+func TestWsReconnectStates(t *testing.T) {
+	s := newFakeWsServer(t)
+	var (
+		mu     sync.Mutex
+		states []WsState
+	)
+	chState := make(chan WsStateEvent, 100)
+	c := newTestWsClient(t, s,
+		WithWsBackoff(ExponentialBackoff{BaseDelay: 10 * time.Millisecond, MaxAttempts: 3}),
+		WithWsStateHandler(func(e WsStateEvent) {
+			mu.Lock()
+			states = append(states, e.State)
+			mu.Unlock()
+			chState <- e
+		}),
+	)
+	defer c.Close()
+	waitState := func(state WsState) WsStateEvent {
+		t.Helper()
+		for {
+			e := recv(t, chState)
+			if e.State == state {
+				return e
+			}
+		}
+	}
+	checkStates := func(expected ...WsState) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if len(states) != len(expected) {
+			t.Fatalf("expected states %v, got %v", expected, states)
+		}
+		for i := range expected {
+			if states[i] != expected[i] {
+				t.Fatalf("expected states %v, got %v", expected, states)
+			}
+		}
+		states = nil
+	}
+	waitState(WS_STATE_WELCOMED)
+	checkStates(WS_STATE_CONNECTING, WS_STATE_CONNECTED, WS_STATE_WELCOMED)
+
+	if _, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-a", Currency: WS_CURRENCY_USD}); err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	s.drop()
+	if e := waitState(WS_STATE_RESUBSCRIBED); e.Attempt != 1 || e.Err != nil {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	s.nextFrame(t)
+	checkStates(WS_STATE_DISCONNECTED, WS_STATE_CONNECTING, WS_STATE_CONNECTED, WS_STATE_WELCOMED, WS_STATE_RESUBSCRIBED)
+
+	s.srv.Listener.Close()
+	s.drop()
+	if e := waitState(WS_STATE_GAVE_UP); e.Attempt != 3 || !errors.Is(e.Err, ErrWsGaveUp) {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	checkStates(
+		WS_STATE_DISCONNECTED,
+		WS_STATE_CONNECTING, WS_STATE_DISCONNECTED,
+		WS_STATE_CONNECTING, WS_STATE_DISCONNECTED,
+		WS_STATE_CONNECTING, WS_STATE_DISCONNECTED,
+		WS_STATE_GAVE_UP,
+	)
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("done not closed")
+	}
+	if !errors.Is(c.Err(), ErrWsGaveUp) {
+		t.Fatalf("expected ErrWsGaveUp, got %v", c.Err())
+	}
+}
+
+func TestWsDispatchOverflow(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s)
+	defer c.Close()
+
+	subscribe := func(address string, opts ...WsSubOption) *Subscription[WsPriceData] {
+		t.Helper()
+		sub, err := c.SubscribePrice(context.Background(), WsPriceSubData{Address: address, Currency: WS_CURRENCY_USD}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.nextFrame(t)
+		return sub
+	}
+	waitDropped := func(sub *Subscription[WsPriceData], n uint64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for sub.Dropped() != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d dropped, got %d", n, sub.Dropped())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	expectPrices := func(sub *Subscription[WsPriceData], prices ...float64) {
+		t.Helper()
+		for _, p := range prices {
+			if d := recv(t, sub.C); d.C != p {
+				t.Fatalf("expected %v, got %+v", p, d)
+			}
+		}
+		select {
+		case d := <-sub.C:
+			t.Fatalf("unexpected message %+v", d)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	newest := subscribe("newest", WithSubBuffer(2))
+	oldest := subscribe("oldest", WithSubBuffer(2), WithSubOverflow(OVERFLOW_DROP_OLDEST))
+	latest := subscribe("latest", WithSubBuffer(2), WithSubOverflow(OVERFLOW_KEEP_LATEST))
+	if _, err := c.SubscribeTxs(context.Background(), WsTxsSubData{Address: "token"}, WithSubOverflow(OVERFLOW_KEEP_LATEST)); err == nil {
+		t.Fatal("expected error for keep latest without key")
+	}
+	for i := 1; i <= 5; i++ {
+		s.push(WS_PRICE_DATA, WsPriceData{Address: "newest", Type: CHART_1m, C: float64(i)})
+		s.push(WS_PRICE_DATA, WsPriceData{Address: "oldest", Type: CHART_1m, C: float64(i)})
+	}
+	waitDropped(newest, 3)
+	waitDropped(oldest, 3)
+	expectPrices(newest, 1, 2)
+	expectPrices(oldest, 4, 5)
+
+	for i := 1; i <= 5; i++ {
+		s.push(WS_PRICE_DATA, WsPriceData{Address: "latest", Type: CHART_1m, C: float64(i)})
+	}
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "latest", Type: CHART_5m, C: 100})
+	last := 0.0
+	for received := uint64(0); received+latest.Dropped() < 6; received++ {
+		d := recv(t, latest.C)
+		if d.Type == CHART_5m {
+			if d.C != 100 {
+				t.Fatalf("unexpected 5m candle %+v", d)
+			}
+			continue
+		}
+		if d.C <= last {
+			t.Fatalf("out of order candle %+v after %v", d, last)
+		}
+		last = d.C
+	}
+	if last != 5 {
+		t.Fatalf("expected the latest 1m candle, got %v", last)
+	}
+
+	block := subscribe("block", WithSubBuffer(1), WithSubOverflow(OVERFLOW_BLOCK))
+	for i := 1; i <= 3; i++ {
+		s.push(WS_PRICE_DATA, WsPriceData{Address: "block", Type: CHART_1m, C: float64(i)})
+	}
+	expectPrices(block, 1, 2, 3)
+	if block.Dropped() != 0 || c.Dropped() != newest.Dropped()+oldest.Dropped()+latest.Dropped() {
+		t.Fatalf("unexpected dropped counts %d %d", block.Dropped(), c.Dropped())
+	}
+}
+
+func TestWsSubscriptionAck(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s, WithWsAckTimeout(time.Hour))
+	defer c.Close()
+
+	a, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-address-a", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-address-b", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	s.nextFrame(t)
+	if a.Status() != WS_SUB_PENDING || b.Status() != WS_SUB_PENDING {
+		t.Fatalf("expected pending subscriptions, got %s %s", a.Status(), b.Status())
+	}
+
+	// the error mentions b, although a is pending too
+	s.push(WS_ERROR_DATA, map[string]any{"message": "invalid address token-address-b"})
+	var serverErr *WsServerError
+	if err := recv(t, c.Errors()); !errors.As(err, &serverErr) || serverErr.Request != b.Request() {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := b.Wait(context.Background()); err == nil || b.Status() != WS_SUB_REJECTED {
+		t.Fatalf("expected rejected subscription, got %s %v", b.Status(), err)
+	}
+	if err := b.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-address-a", Type: CHART_1m})
+	recv(t, a.C)
+	if err := a.Wait(context.Background()); err != nil || a.Status() != WS_SUB_ACTIVE {
+		t.Fatalf("expected active subscription, got %s %v", a.Status(), err)
+	}
+	if len(c.subs) != 1 {
+		t.Fatalf("expected the rejected subscription to be removed, got %d", len(c.subs))
+	}
+
+	// a blocking subscribe returns the rejection
+	go func() {
+		s.nextFrame(t)
+		s.push(WS_ERROR_DATA, "subscription limit exceeded")
+	}()
+	_, err = c.SubscribeTxs(context.Background(), WsTxsSubData{Address: "token-address-c"}, WithSubAck())
+	if !errors.As(err, &serverErr) || serverErr.Message != "subscription limit exceeded" {
+		t.Fatalf("expected server error, got %v", err)
+	}
+	recv(t, c.Errors())
+	if len(c.subs) != 1 {
+		t.Fatalf("expected the rejected subscription to be removed, got %d", len(c.subs))
+	}
+
+	// a rejected subscription shared by two holders is kept until both released it
+	d1, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-address-d", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-address-d", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	s.push(WS_ERROR_DATA, map[string]any{"message": "invalid address token-address-d"})
+	recv(t, c.Errors())
+	if err := d2.Wait(context.Background()); err == nil || d1.Status() != WS_SUB_REJECTED {
+		t.Fatalf("expected rejected subscriptions, got %s %v", d1.Status(), err)
+	}
+	if err := d1.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.subs) != 2 {
+		t.Fatalf("expected the rejected subscription kept for its other holder, got %d", len(c.subs))
+	}
+	if err := c.WsUnsub(d2.Request()); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.subs) != 1 {
+		t.Fatalf("expected the rejected subscription removed, got %d", len(c.subs))
+	}
+	// nothing is sent to unsubscribe a rejected subscription
+	select {
+	case frame := <-s.frames:
+		t.Fatalf("unexpected frame %v", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWsSubscriptionRejectedResubscribe(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s, WithWsAckTimeout(time.Hour), WithWsBackoff(ConstantBackoff(10*time.Millisecond)))
+	defer c.Close()
+
+	req := WsSubData[WsPriceSubData]{Type: SUBSCRIBE_PRICE, Data: WsPriceSubData{QueryType: QUERY_TYPE_SIMPLE, ChartType: CHART_1m, Address: "token-address-r", Currency: WS_CURRENCY_USD}}
+	if err := c.WsSub(req); err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	s.push(WS_ERROR_DATA, "invalid address token-address-r")
+	recv(t, c.Errors())
+	if err := c.WsSub(WsSubData[WsPriceSubData]{Type: SUBSCRIBE_PRICE, Data: WsPriceSubData{QueryType: QUERY_TYPE_SIMPLE, ChartType: CHART_1m, Address: "token-address-s", Currency: WS_CURRENCY_USD}}); err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+
+	// the rejected subscription is not sent again after reconnecting
+	s.drop()
+	frame := s.nextFrame(t)
+	if data := frame["data"].(map[string]any); data["address"] != "token-address-s" {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	select {
+	case frame := <-s.frames:
+		t.Fatalf("unexpected frame %v", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// subscribing again retries it
+	if err := c.WsSub(req); err != nil {
+		t.Fatal(err)
+	}
+	if frame := s.nextFrame(t); frame["data"].(map[string]any)["address"] != "token-address-r" {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	if err := c.WsUnsub(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WsUnsub(req); err != nil {
+		t.Fatal(err)
+	}
+	if frame := s.nextFrame(t); frame["type"] != string(UNSUBSCRIBE_PRICE) {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+}
+
+func TestWsSubscriptionWaitReconnect(t *testing.T) {
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s, WithWsAckTimeout(time.Hour), WithWsBackoff(ConstantBackoff(10*time.Millisecond)))
+	defer c.Close()
+
+	sub, err := c.SubscribePrice(context.Background(), WsPriceSubData{ChartType: CHART_1m, Address: "token-address-w", Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	waited := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		waited <- sub.Wait(ctx)
+	}()
+
+	// the subscription is resent while pending, Wait follows the new round
+	s.drop()
+	s.nextFrame(t)
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-address-w", Type: CHART_1m})
+	recv(t, sub.C)
+	if err := recv(t, waited); err != nil || sub.Status() != WS_SUB_ACTIVE {
+		t.Fatalf("expected active subscription, got %s %v", sub.Status(), err)
+	}
+}
+
+func TestWsManager(t *testing.T) {
+	s := newFakeWsServer(t)
+	m := NewWsManager(CHAIN_SOLANA, "test-key", slog.New(slog.NewTextHandler(io.Discard, nil)), WithManagerMaxAddresses(2))
+	m.newClient = func() *WsClient {
+		// queries are accepted when no error arrived within the ack timeout
+		return NewWsClient(CHAIN_SOLANA, "test-key", m.logger, WithWsBaseURL(s.url()), WithWsAckTimeout(300*time.Millisecond))
+	}
+	defer m.Close()
+
+	price := func(address string) WsPriceSubData {
+		return WsPriceSubData{Address: address, ChartType: CHART_1m, Currency: WS_CURRENCY_USD}
+	}
+	query := func(frame map[string]any) string {
+		return frame["data"].(map[string]any)["query"].(string)
+	}
+	ctx := context.Background()
+	a1, err := m.SubscribePrice(ctx, price("token-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := query(s.nextFrame(t)); q != price("token-a").Query() {
+		t.Fatalf("unexpected query %s", q)
+	}
+	a2, err := m.SubscribePrice(ctx, price("token-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.SubscribePrice(ctx, price("token-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// token-b is added to the query of the first connection, then the old query is unsubscribed
+	if frame := s.nextFrame(t); query(frame) != JoinQuery(price("token-a").Query(), price("token-b").Query()) {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	if frame := s.nextFrame(t); frame["type"] != string(UNSUBSCRIBE_PRICE) {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	c, err := m.SubscribePrice(ctx, price("token-c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := query(s.nextFrame(t)); q != price("token-c").Query() {
+		t.Fatalf("unexpected query %s", q)
+	}
+	if m.Conns() != 2 || s.connCount() != 2 {
+		t.Fatalf("expected 2 connections, got %d", m.Conns())
+	}
+
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-c", Type: CHART_1m, C: 3})
+	if d := recv(t, c.C); d.C != 3 {
+		t.Fatalf("unexpected price %+v", d)
+	}
+
+	// token-a is still used by a2
+	if err := a1.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Conns() != 2 {
+		t.Fatalf("expected 2 connections, got %d", m.Conns())
+	}
+	// token-c moves to the first connection and the second one is closed
+	if err := a2.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if m.Conns() != 1 {
+		t.Fatalf("expected 1 connection, got %d", m.Conns())
+	}
+	// subscribe b+c, unsubscribe a+b, unsubscribe c
+	var queries []string
+	for i := 0; i < 3; i++ {
+		frame := s.nextFrame(t)
+		if frame["type"] == string(SUBSCRIBE_PRICE) {
+			queries = append(queries, query(frame))
+		}
+	}
+	if len(queries) != 1 || queries[0] != JoinQuery(price("token-b").Query(), price("token-c").Query()) {
+		t.Fatalf("unexpected queries %v", queries)
+	}
+	s.mu.Lock()
+	first := s.conns[0]
+	s.mu.Unlock()
+	s.send(first, WS_PRICE_DATA, WsPriceData{Address: "token-c", Type: CHART_1m, C: 4})
+	if d := recv(t, c.C); d.C != 4 {
+		t.Fatalf("unexpected price %+v", d)
+	}
+
+	// subscribe c, unsubscribe b+c
+	if err := b.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if q := query(s.nextFrame(t)); q != price("token-c").Query() {
+		t.Fatalf("unexpected query %s", q)
+	}
+	if frame := s.nextFrame(t); frame["type"] != string(UNSUBSCRIBE_PRICE) {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	// a rejected query leaves the previous one subscribed
+	errc := make(chan error, 1)
+	go func() {
+		_, err := m.SubscribePrice(ctx, price("token-d"))
+		errc <- err
+	}()
+	if q := query(s.nextFrame(t)); q != JoinQuery(price("token-c").Query(), price("token-d").Query()) {
+		t.Fatalf("unexpected query %s", q)
+	}
+	// messages of the previous query do not accept the new one
+	s.send(first, WS_PRICE_DATA, WsPriceData{Address: "token-c", Type: CHART_1m, C: 6})
+	if d := recv(t, c.C); d.C != 6 {
+		t.Fatalf("unexpected price %+v", d)
+	}
+	// subscribing on another connection does not wait for the pending query
+	errce := make(chan error, 1)
+	go func() {
+		_, err := m.SubscribePrice(ctx, price("token-e"))
+		errce <- err
+	}()
+	if q := query(s.nextFrame(t)); q != price("token-e").Query() {
+		t.Fatalf("unexpected query %s", q)
+	}
+	select {
+	case err := <-errc:
+		t.Fatalf("expected pending query, got %v", err)
+	default:
+	}
+	s.send(first, WS_ERROR_DATA, map[string]any{"message": "too many addresses"})
+	var serverErr *WsServerError
+	if err := <-errc; !errors.As(err, &serverErr) {
+		t.Fatalf("expected server error, got %v", err)
+	}
+	if err := <-errce; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case frame := <-s.frames:
+		t.Fatalf("unexpected frame %v", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+	m.mu.Lock()
+	req := m.shards[0].reqs[SUBSCRIBE_PRICE]
+	m.mu.Unlock()
+	if req.Data.Query != price("token-c").Query() {
+		t.Fatalf("expected the previous query kept, got %s", req.Data.Query)
+	}
+	s.send(first, WS_PRICE_DATA, WsPriceData{Address: "token-c", Type: CHART_1m, C: 5})
+	if d := recv(t, c.C); d.C != 5 {
+		t.Fatalf("unexpected price %+v", d)
+	}
+}
+
+func TestWsManagerBatch(t *testing.T) {
+	s := newFakeWsServer(t)
+	m := NewWsManager(CHAIN_SOLANA, "test-key", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.newClient = func() *WsClient {
+		return NewWsClient(CHAIN_SOLANA, "test-key", m.logger, WithWsBaseURL(s.url()), WithWsAckTimeout(300*time.Millisecond))
+	}
+	defer m.Close()
+
+	price := func(address string) WsPriceSubData {
+		return WsPriceSubData{Address: address, ChartType: CHART_1m, Currency: WS_CURRENCY_USD}
+	}
+	query := func(frame map[string]any) string {
+		return frame["data"].(map[string]any)["query"].(string)
+	}
+	ctx := context.Background()
+	if _, err := m.SubscribePrice(ctx, price("token-a")); err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	errc := make(chan error, 3)
+	subscribe := func(address string) {
+		_, err := m.SubscribePrice(ctx, price(address))
+		errc <- err
+	}
+	go subscribe("token-b")
+	if q := query(s.nextFrame(t)); q != JoinQuery(price("token-a").Query(), price("token-b").Query()) {
+		t.Fatalf("unexpected query %s", q)
+	}
+	// c and d are added while a+b is waiting for its ack
+	go subscribe("token-c")
+	go subscribe("token-d")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.mu.Lock()
+		n := len(m.keys)
+		m.mu.Unlock()
+		if n == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 4 keys, got %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// unsubscribe a, subscribe a+b+c+d in one query, unsubscribe a+b
+	if frame := s.nextFrame(t); frame["type"] != string(UNSUBSCRIBE_PRICE) {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	q := query(s.nextFrame(t))
+	if q != JoinQuery(price("token-a").Query(), price("token-b").Query(), price("token-c").Query(), price("token-d").Query()) &&
+		q != JoinQuery(price("token-a").Query(), price("token-b").Query(), price("token-d").Query(), price("token-c").Query()) {
+		t.Fatalf("unexpected query %s", q)
+	}
+	if frame := s.nextFrame(t); frame["type"] != string(UNSUBSCRIBE_PRICE) || query(frame) != JoinQuery(price("token-a").Query(), price("token-b").Query()) {
+		t.Fatalf("unexpected frame %v", frame)
+	}
+	for range 3 {
+		if err := recv(t, errc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case frame := <-s.frames:
+		t.Fatalf("unexpected frame %v", frame)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWsManagerGaveUp(t *testing.T) {
+	s := newFakeWsServer(t)
+	m := NewWsManager(CHAIN_SOLANA, "test-key", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	m.newClient = func() *WsClient {
+		return NewWsClient(CHAIN_SOLANA, "test-key", m.logger, WithWsBaseURL(s.url()), WithWsAckTimeout(50*time.Millisecond),
+			WithWsBackoff(ExponentialBackoff{BaseDelay: 10 * time.Millisecond, MaxAttempts: 2}))
+	}
+	defer m.Close()
+
+	price := WsPriceSubData{Address: "token-a", ChartType: CHART_1m, Currency: WS_CURRENCY_USD}
+	sub, err := m.SubscribePrice(context.Background(), price)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+
+	// the subscriptions of a connection that gave up are closed
+	s.mu.Lock()
+	s.reject = true
+	s.mu.Unlock()
+	s.drop()
+	select {
+	case _, ok := <-sub.C:
+		if ok {
+			t.Fatal("expected closed subscription")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription not closed")
+	}
+	if !errors.Is(sub.Err(), ErrWsGaveUp) {
+		t.Fatalf("expected ErrWsGaveUp, got %v", sub.Err())
+	}
+	if m.Conns() != 0 {
+		t.Fatalf("expected no connection, got %d", m.Conns())
+	}
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	// subscribing again opens a new connection
+	s.mu.Lock()
+	s.reject = false
+	s.mu.Unlock()
+	sub, err = m.SubscribePrice(context.Background(), price)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	s.push(WS_PRICE_DATA, WsPriceData{Address: "token-a", Type: CHART_1m, C: 1})
+	if d := recv(t, sub.C); d.C != 1 {
+		t.Fatalf("unexpected price %+v", d)
+	}
+}
+
+func TestWsHub(t *testing.T) {
+	s := newFakeWsServer(t)
+	h := NewWsHub("test-key", slog.New(slog.NewTextHandler(io.Discard, nil)))
+	gaveUp := make(chan string, 2)
+	h.newClient = func(chain string) *WsClient {
+		return NewWsClient(chain, "test-key", h.logger, WithWsBaseURL(s.url()),
+			WithWsBackoff(ExponentialBackoff{BaseDelay: 10 * time.Millisecond, MaxAttempts: 2}),
+			WithWsStateHandler(func(e WsStateEvent) {
+				if e.State == WS_STATE_GAVE_UP {
+					// the server is back before the hub replaces the client
+					s.mu.Lock()
+					s.reject = false
+					s.mu.Unlock()
+					gaveUp <- chain
+				}
+			}))
+	}
+	defer h.Close()
+
+	stream, err := NewHubStream[WsPriceData](h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHubStream[string](h); err == nil {
+		t.Fatal("expected error for a type that is not a message")
+	}
+	ctx := context.Background()
+	for _, chain := range []string{CHAIN_SOLANA, CHAIN_ETHEREUM} {
+		req := WsSubData[WsPriceSubData]{Type: SUBSCRIBE_PRICE, Data: WsPriceSubData{QueryType: QUERY_TYPE_SIMPLE, Address: "token-" + chain, ChartType: CHART_1m, Currency: WS_CURRENCY_USD}}
+		if err := stream.Subscribe(ctx, chain, req); err != nil {
+			t.Fatal(err)
+		}
+		s.nextFrame(t)
+	}
+	if err := stream.Subscribe(ctx, CHAIN_SOLANA, WsSubData[WsTxsSubData]{Type: SUBSCRIBE_TXS}); err == nil {
+		t.Fatal("expected error for mismatched data type")
+	}
+	if n := s.connCount(); n != 2 {
+		t.Fatalf("expected one connection per chain, got %d", n)
+	}
+
+	s.mu.Lock()
+	conns := append([]*websocket.Conn(nil), s.conns...)
+	s.mu.Unlock()
+	s.send(conns[1], WS_PRICE_DATA, WsPriceData{Address: "token-" + CHAIN_ETHEREUM, Type: CHART_1m, C: 2})
+	if e := recv(t, stream.C); e.Chain != CHAIN_ETHEREUM || e.Data.C != 2 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	s.send(conns[0], WS_PRICE_DATA, WsPriceData{Address: "token-" + CHAIN_SOLANA, Type: CHART_1m, C: 1})
+	if e := recv(t, stream.C); e.Chain != CHAIN_SOLANA || e.Data.C != 1 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	health := h.Health()
+	if !health.Healthy || len(health.Chains) != 2 || health.Chains[0].Chain != CHAIN_ETHEREUM {
+		t.Fatalf("unexpected health %+v", health)
+	}
+	// a chain gives up reconnecting
+	h.mu.Lock()
+	clients := map[string]*WsClient{}
+	for chain, hc := range h.chains {
+		clients[chain] = hc.c
+	}
+	h.mu.Unlock()
+	s.mu.Lock()
+	s.reject = true
+	s.mu.Unlock()
+	s.drop()
+	chain := recv(t, gaveUp)
+
+	// the hub moves the subscriptions of the stream to a new client without waiting for a call,
+	// the other chain resubscribes on its own or is moved too
+	for range 2 {
+		if d := s.nextFrame(t); d["type"] != string(SUBSCRIBE_PRICE) {
+			t.Fatalf("expected subscription sent again, got %v", d)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !h.Health().Healthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected chains connected again, got %+v", h.Health())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	h.mu.Lock()
+	hc := h.chains[chain]
+	h.mu.Unlock()
+	hc.mu.Lock()
+	moved := hc.c != clients[chain]
+	hc.mu.Unlock()
+	if !moved {
+		t.Fatalf("expected a new client for %s", chain)
+	}
+	s.mu.Lock()
+	conns = append([]*websocket.Conn(nil), s.conns...)
+	s.mu.Unlock()
+	for _, conn := range conns {
+		// closed connections fail to write, the other chain filters the address out
+		s.send(conn, WS_PRICE_DATA, WsPriceData{Address: "token-" + chain, Type: CHART_1m, C: 3})
+	}
+	if e := recv(t, stream.C); e.Chain != chain || e.Data.C != 3 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	// closing the stream unsubscribes on the new client
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.nextFrame(t); d["type"] != string(UNSUBSCRIBE_PRICE) {
+		t.Fatalf("expected unsubscription on the new client, got %v", d)
+	}
+	if _, ok := <-stream.C; ok {
+		t.Fatal("expected closed channel")
+	}
+
+	// closing the hub closes the streams
+	other, err := NewHubStream[WsTxsData](h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	select {
+	case _, ok := <-other.C:
+		if ok {
+			t.Fatal("expected closed channel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed")
+	}
+	if _, err := NewHubStream[WsPriceData](h); !errors.Is(err, ErrWsClosed) {
+		t.Fatalf("expected ErrWsClosed, got %v", err)
+	}
+}
+
+func TestWsRecovery(t *testing.T) {
+	const token = "token-address-r"
+	now := time.Now().Unix()
+	trade := func(hash string, ts int64) RespTradesByTokenItem {
+		return RespTradesByTokenItem{TxHash: hash, BlockUnixTime: ts, To: RespTradesByTokenTokenInfo{Address: token}}
+	}
+	trades := []RespTradesByTokenItem{trade("a", now-5), trade("b", now-3)}
+	txTypes := make(chan string, 10)
+	rest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		txTypes <- r.URL.Query().Get("tx_type")
+		afterTime, _ := strconv.ParseInt(r.URL.Query().Get("after_time"), 10, 64)
+		items := []RespTradesByTokenItem{}
+		for _, trade := range trades {
+			if trade.BlockUnixTime > afterTime {
+				items = append(items, trade)
+			}
+		}
+		json.NewEncoder(w).Encode(RespData[RespItems[RespTradesByTokenItem]]{Success: true, Data: RespItems[RespTradesByTokenItem]{Items: items}})
+	}))
+	defer rest.Close()
+
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s,
+		WithWsBackoff(ConstantBackoff(10*time.Millisecond)),
+		WithWsRecovery(NewClient("test-key", nil, WithBaseURL(rest.URL))),
+	)
+	defer c.Close()
+	sub, err := c.SubscribeTxs(context.Background(), WsTxsSubData{Address: token, TxsType: TX_TYPE_ALL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	// txs messages carry no pair address, pair subscriptions can not tell their last message
+	pair, err := c.SubscribeTxs(context.Background(), WsTxsSubData{PairAddress: "pair-address-r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	if pair.reg != wsSuber(pair.suber) {
+		t.Fatal("expected pair subscription not recovered")
+	}
+	s.push(WS_TXS_DATA, WsTxsData{TxHash: "a", BlockUnixTime: now - 5, To: WsTxTokenInfo{Address: token}})
+	if tx := recv(t, sub.C); tx.TxHash != "a" || tx.Backfilled {
+		t.Fatalf("expected live trade a, got %+v", tx)
+	}
+
+	s.drop()
+	s.nextFrame(t)
+	// a was received live, only b is backfilled
+	if tx := recv(t, sub.C); tx.TxHash != "b" || !tx.Backfilled || tx.To.Address != token {
+		t.Fatalf("expected backfilled trade b, got %+v", tx)
+	}
+	// the tx type of the subscription is backfilled
+	if txType := recv(t, txTypes); txType != string(TX_TYPE_ALL) {
+		t.Fatalf("expected backfill of %s trades, got %q", TX_TYPE_ALL, txType)
+	}
+	s.push(WS_TXS_DATA, WsTxsData{TxHash: "c", BlockUnixTime: now, To: WsTxTokenInfo{Address: token}})
+	if tx := recv(t, sub.C); tx.TxHash != "c" || tx.Backfilled {
+		t.Fatalf("expected live trade c, got %+v", tx)
+	}
+}
+
+func TestWsDialOptions(t *testing.T) {
+	s := newFakeWsServer(t)
+	chState := make(chan WsStateEvent, 100)
+	c := newTestWsClient(t, s,
+		WithWsUserAgent("gobe-test"),
+		WithWsHeader("Origin", "https://example.com"),
+		WithWsHandshakeTimeout(time.Second),
+		WithWsReadLimit(512),
+		WithWsBackoff(ConstantBackoff(10*time.Millisecond)),
+		WithWsStateHandler(func(e WsStateEvent) { chState <- e }),
+	)
+	defer c.Close()
+	s.mu.Lock()
+	header := s.header
+	s.mu.Unlock()
+	if ua := header.Get("User-Agent"); ua != "gobe-test" {
+		t.Fatalf("expected user agent gobe-test, got %q", ua)
+	}
+	if origin := header.Values("Origin"); len(origin) != 1 || origin[0] != "https://example.com" {
+		t.Fatalf("expected origin https://example.com, got %v", origin)
+	}
+	if p := header.Get("Sec-WebSocket-Protocol"); p != "echo-protocol" {
+		t.Fatalf("expected default protocol header, got %q", p)
+	}
+
+	// a message over the read limit drops the connection
+	s.push(WS_PRICE_DATA, WsPriceData{Symbol: strings.Repeat("x", 1024)})
+	for {
+		e := recv(t, chState)
+		if e.State == WS_STATE_DISCONNECTED {
+			if !errors.Is(e.Err, websocket.ErrReadLimit) {
+				t.Fatalf("expected read limit error, got %v", e.Err)
+			}
+			break
+		}
+	}
+}
+
+func TestWsRedaction(t *testing.T) {
+	const key = "secret-api-key-0123456789"
+	var logs strings.Builder
+	var muLogs sync.Mutex
+	logger := slog.New(slog.NewTextHandler(writerFunc(func(b []byte) (int, error) {
+		muLogs.Lock()
+		defer muLogs.Unlock()
+		return logs.Write(b)
+	}), nil))
+
+	s := newFakeWsServer(t)
+	c := NewWsClient(CHAIN_SOLANA, key, logger, WithWsBaseURL(s.url()), WithWsAPIKeyHeader())
+	defer c.Close()
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	header, query := s.header, s.query
+	s.mu.Unlock()
+	if header.Get("x-api-key") != key || query.Has("x-api-key") {
+		t.Fatalf("expected api key in header only, got header %v and query %v", header, query)
+	}
+
+	// the key is in the url by default, a failing dial must not leak it
+	c2 := NewWsClient(CHAIN_SOLANA, key, logger,
+		WithWsBaseURL(s.url()),
+		WithWsProxy(func(r *http.Request) (*url.URL, error) {
+			return nil, errors.New("no proxy for " + r.URL.String())
+		}),
+	)
+	defer c2.Close()
+	err := c2.Start()
+	if err == nil {
+		t.Fatal("expected dial error")
+	}
+	if strings.Contains(err.Error(), key) || !strings.Contains(err.Error(), REDACTED) {
+		t.Fatalf("api key not redacted from error: %v", err)
+	}
+	c2.logger.Error("birdeye: test", "url", c2.url, "error", err, "header", header)
+	muLogs.Lock()
+	defer muLogs.Unlock()
+	if strings.Contains(logs.String(), key) {
+		t.Fatalf("api key logged: %s", logs.String())
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
+
+func TestWsRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.ndjson.gz")
+	rec, err := CreateWsRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newFakeWsServer(t)
+	c := newTestWsClient(t, s, WithWsRecorder(rec))
+	sub, err := c.SubscribePrice(context.Background(), WsPriceSubData{Address: "token-a", ChartType: CHART_1m, Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.nextFrame(t)
+	var live []WsPriceData
+	for i, address := range []string{"token-a", "token-b", "token-a"} {
+		s.push(WS_PRICE_DATA, WsPriceData{Address: address, Type: CHART_1m, C: float64(i), UnixTime: int64(i)})
+	}
+	live = append(live, recv(t, sub.C), recv(t, sub.C))
+	c.Close()
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := NewWsReplayClient(CHAIN_SOLANA, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer replay.Close()
+	if err := replay.Start(); err != nil {
+		t.Fatal(err)
+	}
+	rsub, err := replay.SubscribePrice(context.Background(), WsPriceSubData{Address: "token-a", ChartType: CHART_1m, Currency: WS_CURRENCY_USD})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replay.ReplayFile(context.Background(), path, REPLAY_MAX_SPEED); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range live {
+		if got := recv(t, rsub.C); got != expected {
+			t.Fatalf("expected replayed %+v, got %+v", expected, got)
+		}
+	}
+	select {
+	case d := <-rsub.C:
+		t.Fatalf("unexpected replayed message %+v", d)
+	default:
+	}
+
+	// frames are spaced by their receive time divided by the speed
+	var buf bytes.Buffer
+	rec = NewWsRecorder(&buf)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		frame, _ := json.Marshal(map[string]any{"type": WS_PRICE_DATA, "data": WsPriceData{Address: "token-a", Type: CHART_1m}})
+		rec.Record(start.Add(time.Duration(i)*100*time.Millisecond), frame)
+	}
+	rec.Close()
+	begin := time.Now()
+	if err := replay.Replay(context.Background(), &buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("expected replay at twice the speed to take 100ms, took %v", elapsed)
+	}
+	if rsub.Dropped() != 0 || len(rsub.C) != 3 {
+		t.Fatalf("expected 3 replayed messages, got %d", len(rsub.C))
+	}
+}
+
+func TestWsReplayClose(t *testing.T) {
+	var b bytes.Buffer
+	rec := NewWsRecorder(&b)
+	for range 10000 {
+		if err := rec.Record(time.Now(), []byte(`{"type":"ERROR","data":"unavailable"}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// closing while error messages are replayed must not send on the closed error channel
+	c := NewWsReplayClient(CHAIN_SOLANA, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Replay(context.Background(), &b, REPLAY_MAX_SPEED)
+	}()
+	time.Sleep(time.Millisecond)
+	c.Close()
+	if err := recv(t, done); err != nil && !errors.Is(err, ErrWsClosed) {
+		t.Fatal(err)
+	}
+	for range c.Errors() {
+	}
+}
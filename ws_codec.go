@@ -0,0 +1,71 @@
+package gobe
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// WsUnmarshalFunc decodes the json data of a message into v, like json.Unmarshal.
+type WsUnmarshalFunc func(data []byte, v any) error
+
+// WithWsUnmarshal sets the function decoding the data of messages, default json.Unmarshal.
+// It allows plugging in a faster json library, the message envelope is always decoded with encoding/json.
+func WithWsUnmarshal(unmarshal WsUnmarshalFunc) WsOption {
+	return func(c *WsClient) {
+		c.unmarshal = unmarshal
+	}
+}
+
+// wsEnvelope is a message, its data is decoded once its type is known.
+type wsEnvelope struct {
+	Type WsDataType      `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// wsCodec decodes the data messages of one type into pooled structs.
+//
+// A decoded struct is only valid until release, subscribers keeping it must copy it, see clone.
+type wsCodec struct {
+	pool sync.Pool
+	// reset zeroes a struct of the pool, so decoding does not merge into the previous message
+	reset func(v any)
+	// clone returns a pointer to a copy of a struct of the pool
+	clone func(v any) any
+}
+
+func newWsCodec[T any]() *wsCodec {
+	return &wsCodec{
+		pool:  sync.Pool{New: func() any { return new(T) }},
+		reset: func(v any) { *v.(*T) = *new(T) },
+		clone: func(v any) any {
+			cp := *v.(*T)
+			return &cp
+		},
+	}
+}
+
+// wsCodecs decode every data message type of wsDataTypes.
+var wsCodecs = map[WsDataType]*wsCodec{
+	WS_PRICE_DATA:             newWsCodec[WsPriceData](),
+	WS_TXS_DATA:               newWsCodec[WsTxsData](),
+	WS_BASE_QUOTE_PRICE_DATA:  newWsCodec[WsBaseQuotePriceData](),
+	WS_TOKEN_NEW_LISTING_DATA: newWsCodec[WsTokenNewListingData](),
+	WS_NEW_PAIR_DATA:          newWsCodec[WsNewPairData](),
+	WS_TXS_LARGE_TRADE_DATA:   newWsCodec[WsLargeTradeTxsData](),
+	WS_WALLET_TXS_DATA:        newWsCodec[WsWalletTxsData](),
+}
+
+// decode decodes data into a struct of the pool, which must be given back with release.
+func (cd *wsCodec) decode(unmarshal WsUnmarshalFunc, data []byte) (any, error) {
+	v := cd.pool.Get()
+	if err := unmarshal(data, v); err != nil {
+		cd.release(v)
+		return nil, err
+	}
+	return v, nil
+}
+
+func (cd *wsCodec) release(v any) {
+	cd.reset(v)
+	cd.pool.Put(v)
+}
@@ -0,0 +1,160 @@
+package gobe
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// wsSampleMessages are typical messages of every data type.
+var wsSampleMessages = map[WsDataType]string{
+	WS_PRICE_DATA: `{"type":"PRICE_DATA","data":{"o":24.586420063533236,"h":24.586420063533236,"l":24.586420063533236,"c":24.586420063533236,` +
+		`"eventType":"ohlcv","type":"1m","unixTime":1675506000,"v":32.928421816,"symbol":"SOL","address":"So11111111111111111111111111111111111111112"}}`,
+	WS_TXS_DATA: `{"type":"TXS_DATA","data":{"blockUnixTime":1675506000,"owner":"6ELmHJj7Ru3WJiBxbb3MEXiGD7mQWHq3ixPXn3aTJSkr",` +
+		`"source":"raydium","txHash":"5yGyUtnbEcvBnSgbaMWnyzDCEbAhg1TVQK9xRzFpRBvHVGcUFhMJD8ruKfHVbNrcbWZKbN2GfbcQgbPqxcDsQyxk",` +
+		`"alias":null,"isTradeOnBe":false,"platform":"","volumeUSD":64.62,` +
+		`"from":{"symbol":"SOL","decimals":9,"address":"So11111111111111111111111111111111111111112","amount":2000000000,` +
+		`"type":"transfer","typeSwap":"from","uiAmount":2,"price":32.31,"nearestPrice":32.31,"changeAmount":-2000000000,"uiChangeAmount":-2},` +
+		`"to":{"symbol":"USDC","decimals":6,"address":"EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v","amount":64620000,` +
+		`"type":"transfer","typeSwap":"to","uiAmount":64.62,"price":1,"nearestPrice":1,"changeAmount":64620000,"uiChangeAmount":64.62}}}`,
+	WS_BASE_QUOTE_PRICE_DATA: `{"type":"BASE_QUOTE_PRICE_DATA","data":{"o":155.2,"h":155.4,"l":155.1,"c":155.3,"eventType":"ohlcv","type":"1m",` +
+		`"unixTime":1726675200,"v":120.5,"baseAddress":"So11111111111111111111111111111111111111112",` +
+		`"quoteAddress":"EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"}}`,
+	WS_TOKEN_NEW_LISTING_DATA: `{"type":"TOKEN_NEW_LISTING_DATA","data":{"address":"BkQfwVktcbWmxePJN5weHWJZgReWbiz8gzTdFa2w7Uds",` +
+		`"decimals":6,"name":"Worker Cat","symbol":"$MCDCAT","liquidity":12008.845893096313,"liquidityAddedAt":"2024-08-19T03:36:15"}}`,
+	WS_NEW_PAIR_DATA: `{"type":"NEW_PAIR_DATA","data":{"address":"CXV5BEGFMGMhA4cqbzXCLbrBxdqaKEyKpn7BSb5XQHdG","name":"SOL-BOME",` +
+		`"source":"raydium","base":{"address":"So11111111111111111111111111111111111111112","name":"Wrapped SOL","symbol":"SOL","decimals":9},` +
+		`"quote":{"address":"ukHH6c7mMyiWCf1b9pnWe25TSpkDDt3H5pQZgZ74J82","name":"BOOK OF MEME","symbol":"BOME","decimals":6},` +
+		`"txHash":"5hJMqX5GcjWZ3LBrNkVuZVzWT3ta1ARvpXhyNovdrvKfZwuhqtM5vf3v8pzHuh3fqQdB8eYdHZfGbWbSMtsydGXG","blockTime":"2024-08-19T03:52:04"}}`,
+	WS_TXS_LARGE_TRADE_DATA: `{"type":"TXS_LARGE_TRADE_DATA","data":{"blockUnixTime":1724040296,"blockHumanTime":"2024-08-19T04:04:56",` +
+		`"owner":"3nwm7kvYDu3AyZFFJ6MQoGpK6qKqGbPcFFmZM8DDX8VN","source":"raydium","poolAddress":"8sLbNZoA1cfnvMJLPfp98ZLAnFSYCFApfJKMbiXNLwxj",` +
+		`"txHash":"4nZEptxN6yhmrzpL2Xy9FPNs33D2ZpswfdDTVTiyVp8RKBedo1XQXhHeVLyWdvRadQUa6jbNMxB9ivJMy3WtcYwq","volumeUSD":19998.4,"network":"solana",` +
+		`"from":{"address":"So11111111111111111111111111111111111111112","name":"Wrapped SOL","symbol":"SOL","decimals":9,` +
+		`"uiAmount":140.7,"price":142.1,"nearestPrice":142.1,"uiChangeAmount":-140.7},` +
+		`"to":{"address":"EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v","name":"USD Coin","symbol":"USDC","decimals":6,` +
+		`"uiAmount":19998.4,"price":1,"nearestPrice":1,"uiChangeAmount":19998.4}}}`,
+	WS_WALLET_TXS_DATA: `{"type":"WALLET_TXS_DATA","data":{"type":"swap","blockUnixTime":1727346891,"blockHumanTime":"2024-09-26T10:34:51",` +
+		`"owner":"0x7a4ee6a5f5e5a0ba2e6e5eb6d1f3c8f1b0b4f5f1","source":"uniswap_v3",` +
+		`"txHash":"0x9a6da21f2ee9e4e4e9b38a8d5ad5ee2ee4f35b80a2a7e3a58a0d46d4b2dfb0e1","volumeUSD":1562.39,"network":"ethereum",` +
+		`"base":{"symbol":"WETH","decimals":18,"address":"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2","uiAmount":0.6},` +
+		`"quote":{"symbol":"USDC","decimals":6,"address":"0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48","uiAmount":1562.39}}}`,
+}
+
+// decodeWsMessageLegacy is the previous decoding of msgHandler, kept as the benchmark baseline.
+func decodeWsMessageLegacy(b []byte) (any, error) {
+	d := map[string]any{}
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, err
+	}
+	t, _ := d["type"].(string)
+	b, err := json.Marshal(d["data"])
+	if err != nil {
+		return nil, err
+	}
+	dd := wsDataTypes[WsDataType(t)]()
+	return dd, json.Unmarshal(b, dd)
+}
+
+// decodeWsMessage is the decoding of msgHandler.
+func decodeWsMessage(b []byte) (any, func(), error) {
+	var msg wsEnvelope
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return nil, nil, err
+	}
+	codec := wsCodecs[msg.Type]
+	dd, err := codec.decode(json.Unmarshal, msg.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dd, func() { codec.release(dd) }, nil
+}
+
+func TestWsCodec(t *testing.T) {
+	for dt, msg := range wsSampleMessages {
+		legacy, err := decodeWsMessageLegacy([]byte(msg))
+		if err != nil {
+			t.Fatal(dt, err)
+		}
+		dd, release, err := decodeWsMessage([]byte(msg))
+		if err != nil {
+			t.Fatal(dt, err)
+		}
+		a, _ := json.Marshal(legacy)
+		b, _ := json.Marshal(dd)
+		release()
+		if string(a) != string(b) {
+			t.Fatalf("%s decoded differently:\n%s\n%s", dt, a, b)
+		}
+	}
+
+	// a pooled struct does not keep the fields of the previous message
+	dd, release, err := decodeWsMessage([]byte(`{"type":"TXS_DATA","data":{"txHash":"a","alias":"x","volumeUSD":1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := wsCodecs[WS_TXS_DATA].clone(dd).(*WsTxsData)
+	release()
+	dd, release, err = decodeWsMessage([]byte(`{"type":"TXS_DATA","data":{"txHash":"b"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if tx := dd.(*WsTxsData); tx.TxHash != "b" || tx.Alias != nil || tx.VolumeUSD != 0 {
+		t.Fatalf("pooled message kept previous fields: %+v", tx)
+	}
+	if cp.TxHash != "a" || cp.Alias == nil || *cp.Alias != "x" {
+		t.Fatalf("copy changed after release: %+v", cp)
+	}
+}
+
+func BenchmarkWsDecode(b *testing.B) {
+	for _, dt := range []WsDataType{
+		WS_PRICE_DATA, WS_TXS_DATA, WS_BASE_QUOTE_PRICE_DATA, WS_TOKEN_NEW_LISTING_DATA,
+		WS_NEW_PAIR_DATA, WS_TXS_LARGE_TRADE_DATA, WS_WALLET_TXS_DATA,
+	} {
+		msg := []byte(wsSampleMessages[dt])
+		b.Run(string(dt)+"/legacy", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(msg)))
+			for i := 0; i < b.N; i++ {
+				if _, err := decodeWsMessageLegacy(msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(string(dt)+"/pooled", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(msg)))
+			for i := 0; i < b.N; i++ {
+				_, release, err := decodeWsMessage(msg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				release()
+			}
+		})
+	}
+}
+
+// BenchmarkWsMsgHandler measures decoding and delivering to one subscription.
+func BenchmarkWsMsgHandler(b *testing.B) {
+	for _, dt := range []WsDataType{WS_PRICE_DATA, WS_TXS_DATA} {
+		b.Run(string(dt), func(b *testing.B) {
+			c := NewWsClient(CHAIN_SOLANA, "test-key", slog.New(slog.NewTextHandler(io.Discard, nil)))
+			defer c.Close()
+			ch := c.NewDataChan(dt)
+			go func() {
+				for range ch {
+				}
+			}()
+			msg := []byte(wsSampleMessages[dt])
+			b.ReportAllocs()
+			b.SetBytes(int64(len(msg)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c.msgHandler(msg)
+			}
+		})
+	}
+}
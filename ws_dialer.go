@@ -0,0 +1,102 @@
+package gobe
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	WS_BASE_URL = "wss://public-api.birdeye.so/socket"
+)
+
+// WithWsBaseURL overrides WS_BASE_URL, e.g. to point at an httptest server.
+// The client connects to baseURL/chain.
+func WithWsBaseURL(baseURL string) WsOption {
+	return func(c *WsClient) {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// WithWsDialer sets the dialer used to connect, default is a copy of websocket.DefaultDialer.
+// Options setting dialer fields, like WithWsProxy, apply to the dialer set last.
+func WithWsDialer(d *websocket.Dialer) WsOption {
+	return func(c *WsClient) {
+		if d != nil {
+			c.dialer = *d
+		}
+	}
+}
+
+// WithWsProxy sets the proxy of the handshake request, default http.ProxyFromEnvironment.
+// Use http.ProxyURL for a fixed proxy.
+func WithWsProxy(proxy func(*http.Request) (*url.URL, error)) WsOption {
+	return func(c *WsClient) {
+		c.dialer.Proxy = proxy
+	}
+}
+
+// WithWsTLSConfig sets the TLS configuration of the connection.
+func WithWsTLSConfig(cfg *tls.Config) WsOption {
+	return func(c *WsClient) {
+		c.dialer.TLSClientConfig = cfg
+	}
+}
+
+// WithWsHandshakeTimeout sets how long the handshake may take, default 45 seconds.
+func WithWsHandshakeTimeout(d time.Duration) WsOption {
+	return func(c *WsClient) {
+		c.dialer.HandshakeTimeout = d
+	}
+}
+
+// WithWsReadLimit sets the maximum size in bytes of a message,
+// the connection is closed and reconnected when a larger message arrives. Default 0, no limit.
+func WithWsReadLimit(limit int64) WsOption {
+	return func(c *WsClient) {
+		c.readLimit = limit
+	}
+}
+
+// WithWsCompression negotiates permessage-deflate compression with the server.
+func WithWsCompression(enabled bool) WsOption {
+	return func(c *WsClient) {
+		c.dialer.EnableCompression = enabled
+	}
+}
+
+// WithWsUserAgent sets the User-Agent header of the handshake request.
+func WithWsUserAgent(ua string) WsOption {
+	return func(c *WsClient) {
+		c.headers.Set("User-Agent", ua)
+	}
+}
+
+// WithWsAPIKeyHeader sends the api key in the x-api-key header of the handshake request
+// instead of the x-api-key query parameter, keeping it out of proxy and server access logs.
+func WithWsAPIKeyHeader() WsOption {
+	return func(c *WsClient) {
+		c.keyInHeader = true
+	}
+}
+
+// WithWsHeader sets a header of the handshake request, replacing the default Origin headers of the same key.
+// The websocket handshake headers can not be overridden.
+func WithWsHeader(key, value string) WsOption {
+	return func(c *WsClient) {
+		c.headers.Set(key, value)
+	}
+}
+
+// defaultWsHeaders are the headers of the handshake request the birdeye websocket expects.
+func defaultWsHeaders() http.Header {
+	headers := http.Header{}
+	headers.Set("Origin", "ws://public-api.birdeye.so")
+	headers.Set("Sec-WebSocket-Origin", "ws://public-api.birdeye.so")
+	headers.Set("Sec-WebSocket-Protocol", "echo-protocol")
+	return headers
+}
@@ -0,0 +1,316 @@
+package gobe
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultWsSubBufferSize is the default number of messages buffered per subscriber.
+const DefaultWsSubBufferSize = 100
+
+// OverflowPolicy is what a subscriber does with a message when its buffer is full.
+//
+// Messages are decoded and delivered in the read loop of the connection, in the order they arrive,
+// so every subscriber sees the messages of a stream in order.
+type OverflowPolicy int
+
+const (
+	// OVERFLOW_DROP_NEWEST drops the incoming message
+	OVERFLOW_DROP_NEWEST OverflowPolicy = iota
+	// OVERFLOW_DROP_OLDEST drops the oldest buffered message to make room for the incoming one
+	OVERFLOW_DROP_OLDEST
+	// OVERFLOW_BLOCK waits until the consumer makes room, nothing is dropped.
+	// It stalls the whole connection, every other subscriber included,
+	// and the connection is reconnected if it stalls longer than the pong wait, see WithWsHeartbeat.
+	OVERFLOW_BLOCK
+	// OVERFLOW_KEEP_LATEST buffers only the latest message per key, e.g. the latest candle per token,
+	// older buffered messages of the same key are dropped.
+	// The buffer holds at most the buffer size of keys, messages of new keys are dropped when it is full.
+	OVERFLOW_KEEP_LATEST
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OVERFLOW_DROP_NEWEST:
+		return "drop_newest"
+	case OVERFLOW_DROP_OLDEST:
+		return "drop_oldest"
+	case OVERFLOW_BLOCK:
+		return "block"
+	case OVERFLOW_KEEP_LATEST:
+		return "keep_latest"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+}
+
+// wsDataKeys are the default OVERFLOW_KEEP_LATEST keys, messages are pointers to the data struct.
+var wsDataKeys = map[WsDataType]func(any) string{
+	WS_PRICE_DATA: func(d any) string {
+		p := d.(*WsPriceData)
+		return p.Address + "|" + string(p.Type)
+	},
+	WS_BASE_QUOTE_PRICE_DATA: func(d any) string {
+		p := d.(*WsBaseQuotePriceData)
+		return p.BaseAddress + "|" + p.QuoteAddress + "|" + p.Type
+	},
+}
+
+type wsSubConfig struct {
+	bufferSize int
+	overflow   OverflowPolicy
+	// key groups messages for OVERFLOW_KEEP_LATEST
+	key func(any) string
+	// ack makes Subscribe wait for the subscription to be active
+	ack bool
+}
+
+// newSubConfig applies opts to the defaults for messages of type t.
+func newSubConfig(defaults wsSubConfig, t WsDataType, opts []WsSubOption) (wsSubConfig, error) {
+	cfg := defaults
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.overflow == OVERFLOW_KEEP_LATEST && cfg.key == nil {
+		if cfg.key = wsDataKeys[t]; cfg.key == nil {
+			return cfg, fmt.Errorf("birdeye: %s messages have no default key, use WithSubKey", t)
+		}
+	}
+	return cfg, nil
+}
+
+// WsSubOption configures the buffer of one subscription.
+type WsSubOption func(*wsSubConfig)
+
+// WithSubBuffer sets how many messages are buffered, default DefaultWsSubBufferSize.
+func WithSubBuffer(n int) WsSubOption {
+	return func(c *wsSubConfig) {
+		c.bufferSize = n
+	}
+}
+
+// WithSubOverflow sets what happens when the buffer is full, default OVERFLOW_DROP_NEWEST.
+func WithSubOverflow(p OverflowPolicy) WsSubOption {
+	return func(c *wsSubConfig) {
+		c.overflow = p
+	}
+}
+
+// WithSubKey sets the OVERFLOW_KEEP_LATEST key of the messages of type T.
+// Price and base quote price messages are keyed by address and chart type by default.
+func WithSubKey[T any](key func(T) string) WsSubOption {
+	return func(c *wsSubConfig) {
+		c.key = func(d any) string {
+			v, ok := d.(*T)
+			if !ok {
+				return ""
+			}
+			return key(*v)
+		}
+	}
+}
+
+// WithWsDispatch sets the default buffer size and overflow policy of subscribers,
+// NewDataChan channels included. Subscribe can override them per subscription.
+func WithWsDispatch(bufferSize int, overflow OverflowPolicy) WsOption {
+	return func(c *WsClient) {
+		c.dispatch.bufferSize = bufferSize
+		c.dispatch.overflow = overflow
+	}
+}
+
+// Dropped returns how many messages were dropped by all subscribers because their buffer was full.
+func (c *WsClient) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// wsSuber receives the decoded messages of one data type.
+type wsSuber interface {
+	dataType() WsDataType
+	// deliver sends d to the subscriber, d is a pointer to the data struct
+	deliver(d any)
+	close()
+}
+
+// suberEnv is what a chanSuber shares with its owner.
+type suberEnv struct {
+	logger *slog.Logger
+	// dropped counts the dropped messages of all subscribers of the owner
+	dropped *atomic.Uint64
+	// stop unblocks OVERFLOW_BLOCK deliveries when the owner is closed
+	stop <-chan struct{}
+}
+
+func (c *WsClient) suberEnv() suberEnv {
+	return suberEnv{logger: c.logger, dropped: &c.dropped, stop: c.ctx.Done()}
+}
+
+// chanSuber delivers messages of type T on a bounded channel, see OverflowPolicy.
+type chanSuber[T any] struct {
+	env     suberEnv
+	t       WsDataType
+	cfg     wsSubConfig
+	match   func(any) bool
+	convert func(any) (T, bool)
+	ch      chan T
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+	once   sync.Once
+
+	// pending messages of OVERFLOW_KEEP_LATEST, sent by pump in key order
+	muPending sync.Mutex
+	keys      []string
+	latest    map[string]T
+	signal    chan struct{}
+	pumpDone  chan struct{}
+}
+
+func newChanSuber[T any](env suberEnv, t WsDataType, cfg wsSubConfig, match func(any) bool, convert func(any) (T, bool)) *chanSuber[T] {
+	if cfg.bufferSize <= 0 {
+		cfg.bufferSize = DefaultWsSubBufferSize
+	}
+	s := &chanSuber[T]{
+		env:     env,
+		t:       t,
+		cfg:     cfg,
+		match:   match,
+		convert: convert,
+		done:    make(chan struct{}),
+	}
+	if cfg.overflow != OVERFLOW_KEEP_LATEST {
+		s.ch = make(chan T, cfg.bufferSize)
+		return s
+	}
+	// the channel is unbuffered so messages wait in latest, where they can be replaced
+	s.ch = make(chan T)
+	s.latest = make(map[string]T)
+	s.signal = make(chan struct{}, 1)
+	s.pumpDone = make(chan struct{})
+	go s.pump()
+	return s
+}
+
+func (s *chanSuber[T]) dataType() WsDataType {
+	return s.t
+}
+
+func (s *chanSuber[T]) deliver(d any) {
+	if s.match != nil && !s.match(d) {
+		return
+	}
+	v, ok := s.convert(d)
+	if !ok {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	switch s.cfg.overflow {
+	case OVERFLOW_BLOCK:
+		select {
+		case s.ch <- v:
+		case <-s.done:
+		case <-s.env.stop:
+		}
+	case OVERFLOW_DROP_OLDEST:
+		for {
+			select {
+			case s.ch <- v:
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.drop()
+			default:
+			}
+		}
+	case OVERFLOW_KEEP_LATEST:
+		s.keepLatest(d, v)
+	default:
+		select {
+		case s.ch <- v:
+		default:
+			s.drop()
+		}
+	}
+}
+
+func (s *chanSuber[T]) drop() {
+	s.env.dropped.Add(1)
+	// log when the count reaches a power of two, not for every message
+	if n := s.dropped.Add(1); n&(n-1) == 0 {
+		s.env.logger.Warn("birdeye: subscriber buffer is full, dropping messages", "type", s.t, "policy", s.cfg.overflow, "dropped", n)
+	}
+}
+
+func (s *chanSuber[T]) keepLatest(d any, v T) {
+	key := s.cfg.key(d)
+	s.muPending.Lock()
+	if _, ok := s.latest[key]; ok {
+		s.latest[key] = v
+		s.muPending.Unlock()
+		s.drop()
+		return
+	}
+	if len(s.keys) >= s.cfg.bufferSize {
+		s.muPending.Unlock()
+		s.drop()
+		return
+	}
+	s.keys = append(s.keys, key)
+	s.latest[key] = v
+	s.muPending.Unlock()
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// pump sends the pending messages of OVERFLOW_KEEP_LATEST, oldest key first.
+func (s *chanSuber[T]) pump() {
+	defer close(s.pumpDone)
+	for {
+		s.muPending.Lock()
+		if len(s.keys) == 0 {
+			s.muPending.Unlock()
+			select {
+			case <-s.signal:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		key := s.keys[0]
+		s.keys = s.keys[1:]
+		v := s.latest[key]
+		delete(s.latest, key)
+		s.muPending.Unlock()
+		select {
+		case s.ch <- v:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// close closes the channel once every pending deliver has returned.
+// Messages pending in an OVERFLOW_KEEP_LATEST subscriber are discarded.
+func (s *chanSuber[T]) close() {
+	s.once.Do(func() {
+		close(s.done)
+		if s.pumpDone != nil {
+			<-s.pumpDone
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.ch)
+	})
+}
@@ -0,0 +1,413 @@
+package gobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WsHub connects to the websocket of several chains, each chain on its own connection,
+// opened on its first subscription.
+// When the client of a chain stops, e.g. gives up reconnecting, the subscriptions of the streams
+// are moved to a new client, retried with the backoff of the stopped client.
+type WsHub struct {
+	apiKey Secret
+	logger *slog.Logger
+	wsOpts []WsOption
+	// newClient creates the client of a chain
+	newClient func(chain string) *WsClient
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	dropped atomic.Uint64
+
+	mu      sync.Mutex
+	chains  map[string]*hubChain
+	streams map[wsSuber]struct{}
+}
+
+// hubChain is the connection of one chain, mu is held while connecting.
+type hubChain struct {
+	mu sync.Mutex
+	c  *WsClient
+	// subs are the subscriptions of the streams on the chain, moved to the next client
+	// when the current one stops
+	subs map[*tagSuber]WsSubRequest
+
+	muState sync.Mutex
+	last    WsStateEvent
+	// lastErr is the reason of the last disconnection
+	lastErr error
+}
+
+func NewWsHub(apiKey string, logger *slog.Logger, opts ...WsOption) *WsHub {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	if apiKey == "" {
+		panic("birdeye: api key is required")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &WsHub{
+		apiKey:  Secret(apiKey),
+		logger:  logger,
+		wsOpts:  opts,
+		ctx:     ctx,
+		cancel:  cancel,
+		chains:  make(map[string]*hubChain),
+		streams: make(map[wsSuber]struct{}),
+	}
+	h.newClient = func(chain string) *WsClient {
+		return NewWsClient(chain, h.apiKey.Reveal(), h.logger.With("chain", chain), h.wsOpts...)
+	}
+	return h
+}
+
+// Client returns the started client of chain, connecting if it is not connected yet
+// or its previous client stopped, e.g. gave up reconnecting.
+// The subscriptions of the streams on the stopped client are sent again on the new one.
+func (h *WsHub) Client(ctx context.Context, chain string) (*WsClient, error) {
+	return h.client(ctx, chain, nil)
+}
+
+// client is Client, calling fn with the chain and its client while the chain is locked.
+func (h *WsHub) client(ctx context.Context, chain string, fn func(*hubChain, *WsClient) error) (*WsClient, error) {
+	h.mu.Lock()
+	if h.ctx.Err() != nil {
+		h.mu.Unlock()
+		return nil, ErrWsClosed
+	}
+	hc, ok := h.chains[chain]
+	if !ok {
+		hc = &hubChain{subs: map[*tagSuber]WsSubRequest{}}
+		h.chains[chain] = hc
+	}
+	h.mu.Unlock()
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if hc.c == nil || hc.c.Err() != nil {
+		c, err := h.connect(ctx, chain, hc)
+		if err != nil {
+			return nil, err
+		}
+		hc.c = c
+	}
+	if fn != nil {
+		if err := fn(hc, hc.c); err != nil {
+			return nil, err
+		}
+	}
+	return hc.c, nil
+}
+
+// connect starts a new client for chain and moves the subscriptions of the streams to it, hc.mu must be held.
+func (h *WsHub) connect(ctx context.Context, chain string, hc *hubChain) (*WsClient, error) {
+	c := h.newClient(chain)
+	// record the state of the chain, keeping any state handler of the options
+	prev := c.onState
+	c.onState = func(e WsStateEvent) {
+		hc.muState.Lock()
+		hc.last = e
+		if e.State == WS_STATE_DISCONNECTED || e.State == WS_STATE_GAVE_UP {
+			hc.lastErr = e.Err
+		}
+		hc.muState.Unlock()
+		if prev != nil {
+			prev(e)
+		}
+	}
+	if err := c.StartCtx(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	h.mu.Lock()
+	closed := h.ctx.Err() != nil
+	h.mu.Unlock()
+	if closed {
+		c.Close()
+		return nil, ErrWsClosed
+	}
+	for tag, req := range hc.subs {
+		if hc.c != nil {
+			hc.c.removeSuber(tag)
+		}
+		c.addSuber(tag)
+		if _, err := c.wsSub(req); err != nil {
+			// the next call tries again on a new client
+			c.Close()
+			return nil, fmt.Errorf("birdeye: resubscribe hub streams of %s: %w", chain, err)
+		}
+	}
+	go h.watch(chain, hc, c)
+	return c, nil
+}
+
+// watch moves the subscriptions of the streams to a new client when c stops while the hub is open.
+// Failed attempts are retried with the backoff of c, once it gives up the next Client call tries again.
+func (h *WsHub) watch(chain string, hc *hubChain, c *WsClient) {
+	select {
+	case <-c.Done():
+	case <-h.ctx.Done():
+		return
+	}
+	stopped := time.Now()
+	for attempt := 1; ; attempt++ {
+		hc.mu.Lock()
+		if hc.c != c || h.ctx.Err() != nil {
+			// replaced by a Client call or closed with the hub
+			hc.mu.Unlock()
+			return
+		}
+		next, err := h.connect(h.ctx, chain, hc)
+		if err == nil {
+			hc.c = next
+			hc.mu.Unlock()
+			h.logger.Info("birdeye: moved hub streams to a new client", "chain", chain)
+			return
+		}
+		hc.mu.Unlock()
+		delay, ok := c.backoff.Next(attempt, time.Since(stopped))
+		if !ok {
+			h.logger.Error("birdeye: gave up moving hub streams to a new client", "chain", chain, "error", err)
+			return
+		}
+		h.logger.Error("birdeye: failed to move hub streams to a new client, retrying...", "chain", chain, "error", err, "delay", delay)
+		select {
+		case <-time.After(delay):
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
+
+// ChainEvent is a message tagged with the chain it was received on.
+type ChainEvent[T any] struct {
+	Chain string
+	Data  T
+}
+
+// HubStream merges the messages of subscriptions on several chains into one typed stream.
+type HubStream[T any] struct {
+	// C receives the messages of every subscription of the stream
+	C <-chan ChainEvent[T]
+
+	h     *WsHub
+	t     WsDataType
+	suber *chanSuber[ChainEvent[T]]
+
+	mu     sync.Mutex
+	closed bool
+	subs   []hubStreamSub
+}
+
+type hubStreamSub struct {
+	hc    *hubChain
+	req   WsSubRequest
+	suber *tagSuber
+}
+
+// hubEvent is a message and the chain it was received on, delivered to the chanSuber of a HubStream.
+type hubEvent struct {
+	chain string
+	d     any
+}
+
+// NewHubStream creates a stream of messages of type T, e.g. WsPriceData,
+// opts set its buffer and overflow policy.
+func NewHubStream[T any](h *WsHub, opts ...WsSubOption) (*HubStream[T], error) {
+	var t WsDataType
+	for dt, newData := range wsDataTypes {
+		if _, ok := newData().(*T); ok {
+			t = dt
+		}
+	}
+	if t == "" {
+		return nil, fmt.Errorf("birdeye: no websocket message of type %T", *new(T))
+	}
+	cfg, err := newSubConfig(wsSubConfig{bufferSize: DefaultWsSubBufferSize}, t, opts)
+	if err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ctx.Err() != nil {
+		return nil, ErrWsClosed
+	}
+	if key := cfg.key; key != nil {
+		// messages of the same key on different chains are different streams
+		cfg.key = func(d any) string {
+			e := d.(hubEvent)
+			return e.chain + "|" + key(e.d)
+		}
+	}
+	suber := newChanSuber(suberEnv{logger: h.logger, dropped: &h.dropped, stop: h.ctx.Done()}, t, cfg, nil, func(d any) (ChainEvent[T], bool) {
+		e, ok := d.(hubEvent)
+		if !ok {
+			return ChainEvent[T]{}, false
+		}
+		v, ok := e.d.(*T)
+		if !ok {
+			return ChainEvent[T]{}, false
+		}
+		return ChainEvent[T]{Chain: e.chain, Data: *v}, true
+	})
+	h.streams[suber] = struct{}{}
+	return &HubStream[T]{C: suber.ch, h: h, t: t, suber: suber}, nil
+}
+
+// Subscribe sends req on the connection of chain, opening it if needed,
+// and merges its messages into the stream.
+// If the connection of chain stops, the subscription is sent again on the next one.
+func (s *HubStream[T]) Subscribe(ctx context.Context, chain string, req WsSubRequest) error {
+	t, req, err := checkSubRequest[T](req)
+	if err != nil {
+		return err
+	}
+	_, err = s.h.client(ctx, chain, func(hc *hubChain, c *WsClient) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.closed {
+			return ErrWsClosed
+		}
+		tag := &tagSuber{chain: chain, t: t, match: wsSubMatcher(req), out: s.suber}
+		c.addSuber(tag)
+		if _, err := c.wsSub(req); err != nil {
+			c.removeSuber(tag)
+			return err
+		}
+		hc.subs[tag] = req
+		s.subs = append(s.subs, hubStreamSub{hc: hc, req: req, suber: tag})
+		return nil
+	})
+	return err
+}
+
+// Dropped returns how many messages were dropped because the buffer of the stream was full.
+func (s *HubStream[T]) Dropped() uint64 {
+	return s.suber.dropped.Load()
+}
+
+// Close unsubscribes every subscription of the stream and closes C.
+// Connections stay open until the hub is closed.
+func (s *HubStream[T]) Close() error {
+	s.suber.close()
+	s.h.mu.Lock()
+	delete(s.h.streams, s.suber)
+	s.h.mu.Unlock()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	subs := s.subs
+	s.subs = nil
+	s.mu.Unlock()
+	var errs []error
+	for _, sub := range subs {
+		// the chain may have moved to a new client since the subscription
+		sub.hc.mu.Lock()
+		delete(sub.hc.subs, sub.suber)
+		c := sub.hc.c
+		sub.hc.mu.Unlock()
+		c.removeSuber(sub.suber)
+		if err := c.WsUnsub(sub.req); err != nil && !errors.Is(err, ErrWsClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tagSuber tags the messages of one subscription with its chain and forwards them to a HubStream.
+type tagSuber struct {
+	chain string
+	t     WsDataType
+	match func(any) bool
+	out   wsSuber
+}
+
+func (s *tagSuber) dataType() WsDataType {
+	return s.t
+}
+
+func (s *tagSuber) deliver(d any) {
+	if s.match == nil || s.match(d) {
+		s.out.deliver(hubEvent{chain: s.chain, d: d})
+	}
+}
+
+// close does nothing when a client closes, the channel is shared by the chains of the HubStream
+// and closed by HubStream.Close or WsHub.Close.
+func (s *tagSuber) close() {}
+
+// ChainHealth is the connection state of one chain of a WsHub.
+type ChainHealth struct {
+	Chain string
+	// State is the last connection state, empty before the first connection attempt
+	State WsState
+	// Since is when the chain entered State
+	Since time.Time
+	// Connected is whether the connection is usable
+	Connected bool
+	// LastErr is the reason of the last disconnection
+	LastErr error
+}
+
+// HubHealth is the connection state of every chain of a WsHub.
+type HubHealth struct {
+	Chains []ChainHealth
+	// Healthy is whether every chain is connected
+	Healthy bool
+}
+
+// Health returns the connection state of every chain, sorted by chain.
+func (h *WsHub) Health() HubHealth {
+	h.mu.Lock()
+	chains := make(map[string]*hubChain, len(h.chains))
+	for chain, hc := range h.chains {
+		chains[chain] = hc
+	}
+	h.mu.Unlock()
+	health := HubHealth{Healthy: true}
+	for chain, hc := range chains {
+		hc.muState.Lock()
+		ch := ChainHealth{Chain: chain, State: hc.last.State, Since: hc.last.Time, LastErr: hc.lastErr}
+		hc.muState.Unlock()
+		ch.Connected = ch.State == WS_STATE_WELCOMED || ch.State == WS_STATE_RESUBSCRIBED
+		health.Healthy = health.Healthy && ch.Connected
+		health.Chains = append(health.Chains, ch)
+	}
+	sort.Slice(health.Chains, func(i, j int) bool {
+		return health.Chains[i].Chain < health.Chains[j].Chain
+	})
+	return health
+}
+
+// Close closes the connection of every chain and the channel of every stream.
+func (h *WsHub) Close() error {
+	h.mu.Lock()
+	h.cancel()
+	chains := h.chains
+	h.chains = map[string]*hubChain{}
+	streams := h.streams
+	h.streams = map[wsSuber]struct{}{}
+	h.mu.Unlock()
+	for _, hc := range chains {
+		hc.mu.Lock()
+		if hc.c != nil {
+			hc.c.Close()
+		}
+		hc.mu.Unlock()
+	}
+	for suber := range streams {
+		suber.close()
+	}
+	return nil
+}
@@ -0,0 +1,647 @@
+package gobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// WsManager subscribes to many tokens over as few connections as possible.
+//
+// Identical subscriptions share one server subscription, reference counted.
+// Simple price and txs subscriptions are packed into one complex query per connection
+// and subscription type, holding at most the max addresses per connection,
+// new connections are opened when every connection is full.
+// Removing subscriptions moves the keys of the last connections into the free room
+// of the first ones and closes connections left empty.
+// A changed query replaces the previous one once the server accepted it, that is when no ERROR
+// arrived within the ack timeout, see WithWsAckTimeout. Messages do not acknowledge queries,
+// the previous query still produces them. Subscribing waits until the query holding the subscription
+// is accepted and returns the server error if it is rejected. Keys added while a query of a connection
+// is waiting for its ack are sent together in the next query of that connection.
+//
+// Subscriptions of a connection that stopped, e.g. with ErrWsGaveUp, are closed, see ManagedSubscription.Err.
+//
+// Price messages carry no currency, so price subscriptions differing only by currency
+// receive each other's messages. Txs messages carry no pair address,
+// so pairAddress subscriptions receive every txs message of their connection.
+type WsManager struct {
+	chain  string
+	apiKey Secret
+	logger *slog.Logger
+
+	wsOpts       []WsOption
+	maxAddresses int
+	dispatch     wsSubConfig
+	// newClient creates the client of a new connection
+	newClient func() *WsClient
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	dropped atomic.Uint64
+
+	// mu guards shards, keys and the queries of shards, it is not held while waiting for acks
+	mu     sync.Mutex
+	shards []*wsShard
+	keys   map[string]*wsManagedKey
+	// muRoute guards the keys of shards and the subers of keys, it is read locked by message routing
+	muRoute sync.RWMutex
+}
+
+// wsShard is one connection of a WsManager.
+type wsShard struct {
+	c    *WsClient
+	keys map[WsSubType][]*wsManagedKey
+	// reqs are the complex subscriptions accepted for keys
+	reqs map[WsSubType]WsSubData[WsComplexSubData]
+	// syncs send the queries of keys, one at a time per subscription type
+	syncs map[WsSubType]*wsShardSync
+}
+
+// wsShardSync sends the query of the keys of one subscription type of a connection.
+type wsShardSync struct {
+	running bool
+	// waiters receive the result of the next query sent, which holds every key assigned before they were added
+	waiters []chan error
+}
+
+// wsManagedKey is one deduplicated simple subscription.
+type wsManagedKey struct {
+	id      string
+	subType WsSubType
+	cond    Query
+	match   func(any) bool
+	subers  []wsSuber
+	shard   *wsShard
+
+	// ready is closed when a query holding the key was accepted or the key failed with err
+	ready   chan struct{}
+	settled bool
+	err     error
+}
+
+// settle resolves the key once, mu must be held.
+func (k *wsManagedKey) settle(err error) {
+	if k.settled {
+		return
+	}
+	k.settled = true
+	k.err = err
+	close(k.ready)
+}
+
+type WsManagerOption func(*WsManager)
+
+// WithManagerMaxAddresses sets how many subscriptions of one type are packed into one connection,
+// default WS_QUERY_MAX_ADDRESSES.
+func WithManagerMaxAddresses(n int) WsManagerOption {
+	return func(m *WsManager) {
+		m.maxAddresses = n
+	}
+}
+
+// WithManagerWsOptions sets the options of the clients created for every connection.
+func WithManagerWsOptions(opts ...WsOption) WsManagerOption {
+	return func(m *WsManager) {
+		m.wsOpts = append(m.wsOpts, opts...)
+	}
+}
+
+// WithManagerDispatch sets the default buffer size and overflow policy of subscriptions, see WithWsDispatch.
+func WithManagerDispatch(bufferSize int, overflow OverflowPolicy) WsManagerOption {
+	return func(m *WsManager) {
+		m.dispatch.bufferSize = bufferSize
+		m.dispatch.overflow = overflow
+	}
+}
+
+func NewWsManager(chain, apiKey string, logger *slog.Logger, opts ...WsManagerOption) *WsManager {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	}
+	if apiKey == "" {
+		panic("birdeye: api key is required")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &WsManager{
+		chain:        chain,
+		apiKey:       Secret(apiKey),
+		logger:       logger,
+		maxAddresses: WS_QUERY_MAX_ADDRESSES,
+		dispatch:     wsSubConfig{bufferSize: DefaultWsSubBufferSize, overflow: OVERFLOW_DROP_NEWEST},
+		ctx:          ctx,
+		cancel:       cancel,
+		keys:         make(map[string]*wsManagedKey),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.newClient = func() *WsClient {
+		return NewWsClient(m.chain, m.apiKey.Reveal(), m.logger, m.wsOpts...)
+	}
+	return m
+}
+
+// ManagedSubscription is a typed stream of one subscription of a WsManager.
+type ManagedSubscription[T any] struct {
+	// C receives the messages of the subscription
+	C <-chan T
+
+	m     *WsManager
+	key   *wsManagedKey
+	suber *chanSuber[T]
+	once  sync.Once
+}
+
+// Unsubscribe stops the subscription and closes C.
+// The token is removed from the connection when no other subscription uses it.
+func (s *ManagedSubscription[T]) Unsubscribe() error {
+	var err error
+	s.once.Do(func() {
+		s.suber.close()
+		err = s.m.release(s.key, s.suber)
+	})
+	return err
+}
+
+// Dropped returns how many messages were dropped because the buffer of the subscription was full.
+func (s *ManagedSubscription[T]) Dropped() uint64 {
+	return s.suber.dropped.Load()
+}
+
+// Err returns why the manager closed C, the error of the stopped connection, nil otherwise.
+func (s *ManagedSubscription[T]) Err() error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.key.err
+}
+
+// SubscribePrice subscribes to the candles of a token or pair.
+func (m *WsManager) SubscribePrice(ctx context.Context, d WsPriceSubData, opts ...WsSubOption) (*ManagedSubscription[WsPriceData], error) {
+	d.QueryType = QUERY_TYPE_SIMPLE
+	return manage[WsPriceData](ctx, m, SUBSCRIBE_PRICE, PriceCond(d), WsSubData[WsPriceSubData]{Type: SUBSCRIBE_PRICE, Data: d}, opts)
+}
+
+// SubscribeTxs subscribes to the swaps of a token or pair, complex queries can not filter by TxsType.
+func (m *WsManager) SubscribeTxs(ctx context.Context, d WsTxsSubData, opts ...WsSubOption) (*ManagedSubscription[WsTxsData], error) {
+	if d.TxsType != "" && d.TxsType != TX_TYPE_SWAP {
+		return nil, fmt.Errorf("birdeye: managed txs subscriptions can not filter by tx type %q", d.TxsType)
+	}
+	d.TxsType = ""
+	d.QueryType = QUERY_TYPE_SIMPLE
+	return manage[WsTxsData](ctx, m, SUBSCRIBE_TXS, TxsCond(d), WsSubData[WsTxsSubData]{Type: SUBSCRIBE_TXS, Data: d}, opts)
+}
+
+func manage[T any](ctx context.Context, m *WsManager, t WsSubType, cond Query, req WsSubRequest, opts []WsSubOption) (*ManagedSubscription[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := ValidateQuery(t, cond); err != nil {
+		return nil, err
+	}
+	dt := wsSubDataTypes[t]
+	cfg, err := newSubConfig(m.dispatch, dt, opts)
+	if err != nil {
+		return nil, err
+	}
+	suber := newChanSuber(suberEnv{logger: m.logger, dropped: &m.dropped, stop: m.ctx.Done()}, dt, cfg, nil, func(d any) (T, bool) {
+		v, ok := d.(*T)
+		if !ok {
+			return *new(T), false
+		}
+		return *v, true
+	})
+
+	m.mu.Lock()
+	if m.ctx.Err() != nil {
+		m.mu.Unlock()
+		suber.close()
+		return nil, ErrWsClosed
+	}
+	id := string(t) + " " + cond.String()
+	key, ok := m.keys[id]
+	if !ok {
+		key = &wsManagedKey{id: id, subType: t, cond: cond, match: wsSubMatcher(req), ready: make(chan struct{})}
+		if err := m.place(ctx, key); err != nil {
+			m.mu.Unlock()
+			suber.close()
+			return nil, err
+		}
+		m.keys[id] = key
+	}
+	m.muRoute.Lock()
+	key.subers = append(key.subers, suber)
+	m.muRoute.Unlock()
+	m.mu.Unlock()
+
+	sub := &ManagedSubscription[T]{C: suber.ch, m: m, key: key, suber: suber}
+	select {
+	case <-key.ready:
+		m.mu.Lock()
+		err = key.err
+		m.mu.Unlock()
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-m.ctx.Done():
+		err = ErrWsClosed
+	}
+	if err != nil {
+		if uerr := sub.Unsubscribe(); uerr != nil {
+			m.logger.Error("birdeye: failed to release subscription", "error", uerr)
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+// place adds key to the first connection with room, opening a connection if all are full,
+// and requests the query of the connection, the key is ready once it is accepted.
+func (m *WsManager) place(ctx context.Context, key *wsManagedKey) error {
+	var shard *wsShard
+	for _, s := range m.shards {
+		if len(s.keys[key.subType]) < m.maxAddresses {
+			shard = s
+			break
+		}
+	}
+	if shard == nil {
+		s, err := m.openShard(ctx)
+		if err != nil {
+			return err
+		}
+		shard = s
+	}
+	m.assign(shard, key)
+	m.requestSync(shard, key.subType)
+	return nil
+}
+
+func (m *WsManager) openShard(ctx context.Context) (*wsShard, error) {
+	c := m.newClient()
+	if err := c.StartCtx(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	s := &wsShard{
+		c:     c,
+		keys:  map[WsSubType][]*wsManagedKey{},
+		reqs:  map[WsSubType]WsSubData[WsComplexSubData]{},
+		syncs: map[WsSubType]*wsShardSync{},
+	}
+	for _, t := range []WsSubType{SUBSCRIBE_PRICE, SUBSCRIBE_TXS} {
+		c.addSuber(&routeSuber{m: m, shard: s, subType: t})
+	}
+	m.shards = append(m.shards, s)
+	go m.watch(s)
+	return s, nil
+}
+
+// watch closes the subscriptions of a connection which stopped without being closed by the manager,
+// e.g. with ErrWsGaveUp.
+func (m *WsManager) watch(shard *wsShard) {
+	select {
+	case <-shard.c.Done():
+	case <-m.ctx.Done():
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shardIndex(shard) < 0 {
+		return
+	}
+	err := shard.c.Err()
+	m.logger.Error("birdeye: managed connection stopped, closing its subscriptions", "error", err)
+	m.closeShard(shard)
+	m.muRoute.Lock()
+	defer m.muRoute.Unlock()
+	for t, keys := range shard.keys {
+		for _, key := range keys {
+			key.shard = nil
+			if m.keys[key.id] == key {
+				delete(m.keys, key.id)
+			}
+			key.settle(err)
+			key.err = err
+			for _, suber := range key.subers {
+				suber.close()
+			}
+		}
+		delete(shard.keys, t)
+	}
+}
+
+func (m *WsManager) closeShard(shard *wsShard) {
+	for i, s := range m.shards {
+		if s == shard {
+			m.shards = append(m.shards[:i:i], m.shards[i+1:]...)
+			break
+		}
+	}
+	shard.c.Close()
+}
+
+// syncing reports whether a query of the connection is being sent.
+func (s *wsShard) syncing() bool {
+	for _, sync := range s.syncs {
+		if sync.running {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *wsShard) empty() bool {
+	for _, keys := range s.keys {
+		if len(keys) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *WsManager) assign(shard *wsShard, key *wsManagedKey) {
+	m.muRoute.Lock()
+	defer m.muRoute.Unlock()
+	shard.keys[key.subType] = append(shard.keys[key.subType], key)
+	key.shard = shard
+}
+
+func (m *WsManager) unassign(key *wsManagedKey) {
+	m.muRoute.Lock()
+	defer m.muRoute.Unlock()
+	keys := key.shard.keys[key.subType]
+	for i, k := range keys {
+		if k == key {
+			key.shard.keys[key.subType] = append(keys[:i:i], keys[i+1:]...)
+			break
+		}
+	}
+	key.shard = nil
+}
+
+// requestSync asks for the query of the keys of type t of a connection to be sent,
+// the channel receives the result of a query holding every key assigned so far. mu must be held.
+func (m *WsManager) requestSync(shard *wsShard, t WsSubType) <-chan error {
+	s, ok := shard.syncs[t]
+	if !ok {
+		s = &wsShardSync{}
+		shard.syncs[t] = s
+	}
+	ch := make(chan error, 1)
+	s.waiters = append(s.waiters, ch)
+	if !s.running {
+		s.running = true
+		go m.runSync(shard, t, s)
+	}
+	return ch
+}
+
+// runSync sends queries until every waiter of s received a result,
+// then closes the connection if it is left without keys.
+func (m *WsManager) runSync(shard *wsShard, t WsSubType, s *wsShardSync) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(s.waiters) > 0 {
+		waiters := s.waiters
+		s.waiters = nil
+		err := m.sync(shard, t)
+		for _, w := range waiters {
+			w <- err
+		}
+	}
+	s.running = false
+	if shard.empty() && !shard.syncing() && m.shardIndex(shard) >= 0 {
+		m.closeShard(shard)
+	}
+}
+
+// sync sends the complex query of the keys of a connection and waits until the server accepted it,
+// then unsubscribes the previous one. If the server rejects it, the previous one stays subscribed
+// and the keys not accepted yet fail. mu must be held, it is released while waiting.
+func (m *WsManager) sync(shard *wsShard, t WsSubType) error {
+	old, subscribed := shard.reqs[t]
+	keys := append([]*wsManagedKey(nil), shard.keys[t]...)
+	if len(keys) == 0 {
+		delete(shard.reqs, t)
+		if subscribed {
+			if err := shard.c.WsUnsub(old); err != nil && m.ctx.Err() == nil {
+				m.logger.Error("birdeye: failed to unsubscribe previous query", "error", err)
+			}
+		}
+		return nil
+	}
+	conds := make([]Query, len(keys))
+	for i, k := range keys {
+		conds[i] = k.cond
+	}
+	d, err := ComplexSub(t, Or(conds...))
+	if err != nil {
+		m.reject(shard, keys, err)
+		return err
+	}
+	req := WsSubData[WsComplexSubData]{Type: t, Data: d}
+	if subscribed && req == old {
+		m.accept(shard, keys)
+		return nil
+	}
+	// the previous query produces messages of the same keys, only the ack timeout accepts the new one
+	entry, err := shard.c.sendSub(req, true)
+	if err == nil {
+		m.mu.Unlock()
+		err = shard.c.waitSettled(m.ctx, entry)
+		m.mu.Lock()
+		if err != nil {
+			if err := shard.c.WsUnsub(req); err != nil && m.ctx.Err() == nil {
+				m.logger.Error("birdeye: failed to release rejected query", "error", err)
+			}
+		}
+	}
+	if err != nil {
+		m.reject(shard, keys, err)
+		return err
+	}
+	shard.reqs[t] = req
+	if subscribed {
+		if err := shard.c.WsUnsub(old); err != nil {
+			m.logger.Error("birdeye: failed to unsubscribe previous query", "error", err)
+		}
+	}
+	m.accept(shard, keys)
+	return nil
+}
+
+// accept settles the keys still assigned to the connection whose query was accepted.
+func (m *WsManager) accept(shard *wsShard, keys []*wsManagedKey) {
+	for _, k := range keys {
+		if k.shard == shard {
+			k.settle(nil)
+		}
+	}
+}
+
+// reject removes the keys of a rejected query never accepted before, they fail with err.
+func (m *WsManager) reject(shard *wsShard, keys []*wsManagedKey, err error) {
+	for _, k := range keys {
+		if k.shard != shard || k.settled {
+			continue
+		}
+		m.unassign(k)
+		if m.keys[k.id] == k {
+			delete(m.keys, k.id)
+		}
+		k.settle(err)
+	}
+}
+
+// release removes a subscriber of key, the key is removed from its connection with its last subscriber.
+func (m *WsManager) release(key *wsManagedKey, suber wsSuber) error {
+	m.mu.Lock()
+	m.muRoute.Lock()
+	for i, s := range key.subers {
+		if s == suber {
+			key.subers = append(key.subers[:i:i], key.subers[i+1:]...)
+			break
+		}
+	}
+	last := len(key.subers) == 0
+	m.muRoute.Unlock()
+	if !last || key.shard == nil || m.ctx.Err() != nil {
+		m.mu.Unlock()
+		return nil
+	}
+	if m.keys[key.id] == key {
+		delete(m.keys, key.id)
+	}
+	shard := key.shard
+	m.unassign(key)
+	dsts, srcs := m.rebalance(key.subType, shard)
+
+	// keys moved to the first connections are subscribed before being unsubscribed from the last ones
+	var errs []error
+	for _, shards := range [][]*wsShard{dsts, srcs} {
+		var results []<-chan error
+		for _, s := range shards {
+			if m.shardIndex(s) >= 0 {
+				results = append(results, m.requestSync(s, key.subType))
+			}
+		}
+		m.mu.Unlock()
+		for _, r := range results {
+			errs = append(errs, <-r)
+		}
+		m.mu.Lock()
+	}
+	m.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// rebalance moves keys of type t from the last connections into the room of the first ones.
+// It returns the connections which received keys and the other changed connections,
+// whose queries must be sent in this order. Connections left without keys are closed once their query is sent.
+func (m *WsManager) rebalance(t WsSubType, changed *wsShard) (dsts, srcs []*wsShard) {
+	received := map[*wsShard]bool{}
+	lost := map[*wsShard]bool{changed: true}
+	for {
+		var dst, src *wsShard
+		for _, s := range m.shards {
+			if len(s.keys[t]) < m.maxAddresses {
+				dst = s
+				break
+			}
+		}
+		for i := len(m.shards) - 1; i >= 0; i-- {
+			if len(m.shards[i].keys[t]) > 0 {
+				src = m.shards[i]
+				break
+			}
+		}
+		if dst == nil || src == nil || m.shardIndex(dst) >= m.shardIndex(src) {
+			break
+		}
+		keys := src.keys[t]
+		key := keys[len(keys)-1]
+		m.unassign(key)
+		m.assign(dst, key)
+		received[dst], lost[src] = true, true
+	}
+	for _, s := range m.shards {
+		if received[s] {
+			dsts = append(dsts, s)
+		} else if lost[s] {
+			srcs = append(srcs, s)
+		}
+	}
+	return dsts, srcs
+}
+
+func (m *WsManager) shardIndex(shard *wsShard) int {
+	for i, s := range m.shards {
+		if s == shard {
+			return i
+		}
+	}
+	return -1
+}
+
+// Conns returns the number of open connections.
+func (m *WsManager) Conns() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.shards)
+}
+
+// Dropped returns how many messages were dropped by all subscriptions because their buffer was full.
+func (m *WsManager) Dropped() uint64 {
+	return m.dropped.Load()
+}
+
+// Close closes every connection and subscription channel.
+func (m *WsManager) Close() error {
+	m.cancel()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.shards {
+		s.c.Close()
+	}
+	m.shards = nil
+	m.muRoute.Lock()
+	defer m.muRoute.Unlock()
+	for id, key := range m.keys {
+		for _, suber := range key.subers {
+			suber.close()
+		}
+		delete(m.keys, id)
+	}
+	return nil
+}
+
+// routeSuber delivers the messages of a connection to the managed subscriptions of its keys.
+type routeSuber struct {
+	m       *WsManager
+	shard   *wsShard
+	subType WsSubType
+}
+
+func (r *routeSuber) dataType() WsDataType {
+	return wsSubDataTypes[r.subType]
+}
+
+func (r *routeSuber) deliver(d any) {
+	r.m.muRoute.RLock()
+	var subers []wsSuber
+	for _, key := range r.shard.keys[r.subType] {
+		if key.match == nil || key.match(d) {
+			subers = append(subers, key.subers...)
+		}
+	}
+	r.m.muRoute.RUnlock()
+	for _, s := range subers {
+		s.deliver(d)
+	}
+}
+
+func (r *routeSuber) close() {}
@@ -0,0 +1,375 @@
+package gobe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WS_QUERY_MAX_ADDRESSES is the maximum number of distinct addresses birdeye accepts in one complex query.
+const WS_QUERY_MAX_ADDRESSES = 100
+
+// wsQueryMaxTerms caps the expansion of nested queries into an OR of ANDs.
+const wsQueryMaxTerms = 1000
+
+type QueryOp string
+
+const (
+	QUERY_AND QueryOp = "AND"
+	QUERY_OR  QueryOp = "OR"
+)
+
+type QueryField string
+
+const (
+	QUERY_FIELD_ADDRESS      QueryField = "address"
+	QUERY_FIELD_PAIR_ADDRESS QueryField = "pairAddress"
+	QUERY_FIELD_CHART_TYPE   QueryField = "chartType"
+	QUERY_FIELD_CURRENCY     QueryField = "currency"
+)
+
+// Query is a complex subscription query, a condition like "address = X"
+// or an AND/OR group of queries. Build it with Cond, And, Or, PriceCond and TxsCond,
+// then turn it into a subscription with ComplexSub.
+type Query struct {
+	// Op is the operator of a group, empty for a condition
+	Op    QueryOp
+	Terms []Query
+	Field QueryField
+	Value string
+}
+
+// Cond returns the condition field = value.
+func Cond(field QueryField, value string) Query {
+	return Query{Field: field, Value: value}
+}
+
+// And returns the conjunction of queries, nested AND groups are flattened.
+func And(queries ...Query) Query {
+	return group(QUERY_AND, queries)
+}
+
+// Or returns the disjunction of queries, nested OR groups are flattened.
+func Or(queries ...Query) Query {
+	return group(QUERY_OR, queries)
+}
+
+func group(op QueryOp, queries []Query) Query {
+	var terms []Query
+	for _, q := range queries {
+		if q.Op == op {
+			terms = append(terms, q.Terms...)
+		} else {
+			terms = append(terms, q)
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return Query{Op: op, Terms: terms}
+}
+
+// PriceCond returns the query of a simple price subscription.
+func PriceCond(d WsPriceSubData) Query {
+	return And(
+		Cond(QUERY_FIELD_ADDRESS, d.Address),
+		Cond(QUERY_FIELD_CHART_TYPE, string(d.ChartType)),
+		Cond(QUERY_FIELD_CURRENCY, string(d.Currency)),
+	)
+}
+
+// TxsCond returns the query of a simple txs subscription.
+func TxsCond(d WsTxsSubData) Query {
+	if d.Address != "" {
+		return Cond(QUERY_FIELD_ADDRESS, d.Address)
+	}
+	return Cond(QUERY_FIELD_PAIR_ADDRESS, d.PairAddress)
+}
+
+// String formats the query like birdeye expects it, AND groups are always parenthesized,
+// e.g. "(address = X AND chartType = 1m AND currency = usd) OR (address = Y AND chartType = 1m AND currency = usd)".
+func (q Query) String() string {
+	return q.format(false)
+}
+
+func (q Query) format(nested bool) string {
+	if q.Op == "" {
+		return fmt.Sprintf("%s = %s", q.Field, q.Value)
+	}
+	terms := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		terms[i] = t.format(true)
+	}
+	s := strings.Join(terms, " "+string(q.Op)+" ")
+	if q.Op == QUERY_AND || nested {
+		s = "(" + s + ")"
+	}
+	return s
+}
+
+// dnf expands q into an OR of ANDs of conditions.
+func (q Query) dnf() ([][]Query, error) {
+	switch q.Op {
+	case "":
+		return [][]Query{{q}}, nil
+	case QUERY_OR:
+		var out [][]Query
+		for _, t := range q.Terms {
+			terms, err := t.dnf()
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, terms...)
+			if len(out) > wsQueryMaxTerms {
+				return nil, fmt.Errorf("birdeye: query expands to more than %d terms", wsQueryMaxTerms)
+			}
+		}
+		return out, nil
+	case QUERY_AND:
+		out := [][]Query{nil}
+		for _, t := range q.Terms {
+			terms, err := t.dnf()
+			if err != nil {
+				return nil, err
+			}
+			if len(out)*len(terms) > wsQueryMaxTerms {
+				return nil, fmt.Errorf("birdeye: query expands to more than %d terms", wsQueryMaxTerms)
+			}
+			product := make([][]Query, 0, len(out)*len(terms))
+			for _, a := range out {
+				for _, b := range terms {
+					product = append(product, append(append([]Query(nil), a...), b...))
+				}
+			}
+			out = product
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("birdeye: invalid query operator %q", q.Op)
+}
+
+// Normalize expands q into an OR of ANDs, the only form birdeye documents,
+// e.g. (address = X OR address = Y) AND chartType = 1m becomes
+// (address = X AND chartType = 1m) OR (address = Y AND chartType = 1m).
+func (q Query) Normalize() (Query, error) {
+	terms, err := q.dnf()
+	if err != nil {
+		return Query{}, err
+	}
+	ors := make([]Query, len(terms))
+	for i, t := range terms {
+		ors[i] = And(t...)
+	}
+	return Or(ors...), nil
+}
+
+// wsQueryFields are the fields every AND term of a complex query must set, per subscription type.
+var wsQueryFields = map[WsSubType][][]QueryField{
+	SUBSCRIBE_PRICE: {{QUERY_FIELD_ADDRESS, QUERY_FIELD_CHART_TYPE, QUERY_FIELD_CURRENCY}},
+	SUBSCRIBE_TXS:   {{QUERY_FIELD_ADDRESS}, {QUERY_FIELD_PAIR_ADDRESS}},
+}
+
+// ValidateQuery checks that q is a valid complex query of subscription type t:
+// every term of SUBSCRIBE_PRICE sets address, chartType and currency once,
+// every term of SUBSCRIBE_TXS is a single address or pairAddress condition,
+// values are well formed and at most WS_QUERY_MAX_ADDRESSES distinct addresses are used.
+func ValidateQuery(t WsSubType, q Query) error {
+	shapes, ok := wsQueryFields[t]
+	if !ok {
+		return fmt.Errorf("birdeye: %s does not support complex queries", t)
+	}
+	terms, err := q.dnf()
+	if err != nil {
+		return err
+	}
+	addresses := map[string]bool{}
+	for _, term := range terms {
+		fields := map[QueryField]bool{}
+		for _, cond := range term {
+			if err := validateQueryCond(cond); err != nil {
+				return err
+			}
+			if fields[cond.Field] {
+				return fmt.Errorf("birdeye: %s is set twice in query term %s", cond.Field, And(term...))
+			}
+			fields[cond.Field] = true
+			if cond.Field == QUERY_FIELD_ADDRESS || cond.Field == QUERY_FIELD_PAIR_ADDRESS {
+				addresses[cond.Value] = true
+			}
+		}
+		if !matchesQueryShape(fields, shapes) {
+			return fmt.Errorf("birdeye: invalid %s query term %s, expected fields %v", t, And(term...), shapes)
+		}
+	}
+	if len(addresses) > WS_QUERY_MAX_ADDRESSES {
+		return fmt.Errorf("birdeye: query has %d addresses, at most %d are allowed", len(addresses), WS_QUERY_MAX_ADDRESSES)
+	}
+	return nil
+}
+
+func matchesQueryShape(fields map[QueryField]bool, shapes [][]QueryField) bool {
+	for _, shape := range shapes {
+		if len(shape) != len(fields) {
+			continue
+		}
+		ok := true
+		for _, f := range shape {
+			ok = ok && fields[f]
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateQueryCond(q Query) error {
+	if q.Value == "" {
+		return fmt.Errorf("birdeye: empty %s in query", q.Field)
+	}
+	// the query syntax has no escaping
+	if strings.ContainsAny(q.Value, " \t\r\n()=") {
+		return fmt.Errorf("birdeye: invalid %s %q in query", q.Field, q.Value)
+	}
+	switch q.Field {
+	case QUERY_FIELD_ADDRESS, QUERY_FIELD_PAIR_ADDRESS:
+	case QUERY_FIELD_CHART_TYPE:
+		if !ChartType(q.Value).Valid() {
+			return fmt.Errorf("birdeye: invalid chart type %q in query", q.Value)
+		}
+	case QUERY_FIELD_CURRENCY:
+		if c := WsCurrency(q.Value); c != WS_CURRENCY_USD && c != WS_CURRENCY_PAIR {
+			return fmt.Errorf("birdeye: invalid currency %q in query", q.Value)
+		}
+	default:
+		return fmt.Errorf("birdeye: unknown query field %q", q.Field)
+	}
+	return nil
+}
+
+// ComplexSub validates q and returns the complex subscription data of type t with the normalized query.
+func ComplexSub(t WsSubType, q Query) (WsComplexSubData, error) {
+	if err := ValidateQuery(t, q); err != nil {
+		return WsComplexSubData{}, err
+	}
+	q, err := q.Normalize()
+	if err != nil {
+		return WsComplexSubData{}, err
+	}
+	return WsComplexSubData{QueryType: QUERY_TYPE_COMPLEX, Query: q.String()}, nil
+}
+
+func validateComplexQuery(t WsSubType, s string) error {
+	q, err := ParseQuery(s)
+	if err != nil {
+		return err
+	}
+	return ValidateQuery(t, q)
+}
+
+// ParseQuery parses a complex query string, AND binds tighter than OR.
+// Formatting the result with String gives back the query in canonical form.
+func ParseQuery(s string) (Query, error) {
+	p := &queryParser{tokens: tokenizeQuery(s)}
+	q, err := p.or()
+	if err != nil {
+		return Query{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return Query{}, fmt.Errorf("birdeye: unexpected %q in query", p.tokens[p.pos])
+	}
+	return q, nil
+}
+
+func tokenizeQuery(s string) []string {
+	var tokens []string
+	word := strings.Builder{}
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range s {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			flush()
+		case '(', ')', '=':
+			flush()
+			tokens = append(tokens, string(r))
+		default:
+			word.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *queryParser) or() (Query, error) {
+	return p.list(QUERY_OR, p.and)
+}
+
+func (p *queryParser) and() (Query, error) {
+	return p.list(QUERY_AND, p.factor)
+}
+
+func (p *queryParser) list(op QueryOp, parse func() (Query, error)) (Query, error) {
+	q, err := parse()
+	if err != nil {
+		return Query{}, err
+	}
+	terms := []Query{q}
+	for strings.EqualFold(p.peek(), string(op)) {
+		p.next()
+		q, err := parse()
+		if err != nil {
+			return Query{}, err
+		}
+		terms = append(terms, q)
+	}
+	return group(op, terms), nil
+}
+
+func (p *queryParser) factor() (Query, error) {
+	switch t := p.next(); t {
+	case "":
+		return Query{}, fmt.Errorf("birdeye: unexpected end of query")
+	case "(":
+		q, err := p.or()
+		if err != nil {
+			return Query{}, err
+		}
+		if t := p.next(); t != ")" {
+			return Query{}, fmt.Errorf("birdeye: expected ) in query, got %q", t)
+		}
+		return q, nil
+	case ")", "=":
+		return Query{}, fmt.Errorf("birdeye: unexpected %q in query", t)
+	default:
+		if p.next() != "=" {
+			return Query{}, fmt.Errorf("birdeye: expected = after %q in query", t)
+		}
+		v := p.next()
+		if v == "" || v == "(" || v == ")" || v == "=" {
+			return Query{}, fmt.Errorf("birdeye: missing value of %q in query", t)
+		}
+		return Cond(QueryField(t), v), nil
+	}
+}
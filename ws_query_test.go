@@ -0,0 +1,93 @@
+package gobe_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dwdwow/gobe"
+)
+
+func TestComplexSub(t *testing.T) {
+	q := gobe.And(
+		gobe.Or(gobe.Cond(gobe.QUERY_FIELD_ADDRESS, "token-a"), gobe.Cond(gobe.QUERY_FIELD_ADDRESS, "token-b")),
+		gobe.Cond(gobe.QUERY_FIELD_CHART_TYPE, "1m"),
+		gobe.Cond(gobe.QUERY_FIELD_CURRENCY, "usd"),
+	)
+	if s := q.String(); s != "((address = token-a OR address = token-b) AND chartType = 1m AND currency = usd)" {
+		t.Fatalf("unexpected query %s", s)
+	}
+	d, err := gobe.ComplexSub(gobe.SUBSCRIBE_PRICE, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := gobe.JoinQuery(
+		gobe.WsPriceSubData{Address: "token-a", ChartType: gobe.CHART_1m, Currency: gobe.WS_CURRENCY_USD}.Query(),
+		gobe.WsPriceSubData{Address: "token-b", ChartType: gobe.CHART_1m, Currency: gobe.WS_CURRENCY_USD}.Query(),
+	)
+	if d.Query != expected || d.QueryType != gobe.QUERY_TYPE_COMPLEX {
+		t.Fatalf("expected %s, got %+v", expected, d)
+	}
+
+	invalid := map[gobe.WsSubType]gobe.Query{
+		gobe.SUBSCRIBE_PRICE:    gobe.And(gobe.Cond(gobe.QUERY_FIELD_ADDRESS, "token-a"), gobe.Cond(gobe.QUERY_FIELD_CHART_TYPE, "1m")),
+		gobe.SUBSCRIBE_TXS:      gobe.And(gobe.Cond(gobe.QUERY_FIELD_ADDRESS, "token-a"), gobe.Cond(gobe.QUERY_FIELD_PAIR_ADDRESS, "pair-a")),
+		gobe.SUBSCRIBE_NEW_PAIR: gobe.Cond(gobe.QUERY_FIELD_ADDRESS, "token-a"),
+	}
+	for typ, q := range invalid {
+		if err := gobe.ValidateQuery(typ, q); err == nil {
+			t.Fatalf("%s: expected error for %s", typ, q)
+		}
+	}
+	if err := gobe.ValidateQuery(gobe.SUBSCRIBE_PRICE, gobe.PriceCond(gobe.WsPriceSubData{Address: "token-a", ChartType: "2m", Currency: gobe.WS_CURRENCY_USD})); err == nil {
+		t.Fatal("expected error for invalid chart type")
+	}
+	if err := gobe.ValidateQuery(gobe.SUBSCRIBE_TXS, gobe.Cond(gobe.QUERY_FIELD_ADDRESS, "token a")); err == nil {
+		t.Fatal("expected error for address with a space")
+	}
+
+	var txs []gobe.Query
+	for i := 0; i <= gobe.WS_QUERY_MAX_ADDRESSES; i++ {
+		txs = append(txs, gobe.TxsCond(gobe.WsTxsSubData{Address: fmt.Sprintf("token-%d", i)}))
+	}
+	if err := gobe.ValidateQuery(gobe.SUBSCRIBE_TXS, gobe.Or(txs...)); err == nil {
+		t.Fatal("expected error for too many addresses")
+	}
+	if err := gobe.ValidateQuery(gobe.SUBSCRIBE_TXS, gobe.Or(txs[1:]...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	for _, s := range []string{
+		"address = token-a",
+		"address = token-a OR pairAddress = pair-b",
+		"(address = token-a AND chartType = 1m AND currency = usd) OR (address = token-b AND chartType = 5m AND currency = pair)",
+		"((address = token-a OR address = token-b) AND chartType = 1m AND currency = usd)",
+	} {
+		q, err := gobe.ParseQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.String() != s {
+			t.Fatalf("expected %s, got %s", s, q)
+		}
+	}
+
+	q, err := gobe.ParseQuery("address=token-a and (chartType = 1m) or address = token-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := q.String(); s != "(address = token-a AND chartType = 1m) OR address = token-b" {
+		t.Fatalf("unexpected query %s", s)
+	}
+
+	for _, s := range []string{"", "address", "address =", "(address = a", "address = a OR", "address = a)"} {
+		if _, err := gobe.ParseQuery(s); err == nil {
+			t.Fatalf("%q: expected error", s)
+		}
+	}
+	if _, err := gobe.ParseQuery(strings.Repeat("(", 3) + "address = a" + strings.Repeat(")", 3)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,121 @@
+package gobe
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// WsBackoff decides when WsClient tries to reconnect after losing its connection.
+type WsBackoff interface {
+	// Next returns the delay before the reconnect attempt following the failed attempt,
+	// attempt starts at 1 and elapsed is the time since the connection was lost.
+	// Returning false gives up reconnecting and stops the client.
+	Next(attempt int, elapsed time.Duration) (time.Duration, bool)
+}
+
+// ExponentialBackoff doubles the delay after every failed attempt, with jitter.
+type ExponentialBackoff struct {
+	// BaseDelay is the delay after the first failed attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the delay, <= 0 means no cap
+	MaxDelay time.Duration
+	// MaxAttempts is the number of attempts before giving up, <= 0 means unlimited
+	MaxAttempts int
+	// MaxElapsed is how long to keep trying since the connection was lost, <= 0 means unlimited
+	MaxElapsed time.Duration
+	// Jitter is the random fraction, 0 to 1, taken off each delay. 0.5 makes delays fall in [d/2, d].
+	Jitter float64
+}
+
+// DefaultWsBackoff retries forever, from 1s up to 30s between attempts.
+var DefaultWsBackoff = ExponentialBackoff{
+	BaseDelay: time.Second,
+	MaxDelay:  30 * time.Second,
+	Jitter:    0.5,
+}
+
+func (b ExponentialBackoff) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt >= b.MaxAttempts {
+		return 0, false
+	}
+	d := b.BaseDelay
+	for i := 1; i < attempt && (b.MaxDelay <= 0 || d < b.MaxDelay); i++ {
+		d *= 2
+	}
+	if b.MaxDelay > 0 && d > b.MaxDelay {
+		d = b.MaxDelay
+	}
+	if j := min(max(b.Jitter, 0), 1); j > 0 && d > 0 {
+		d -= time.Duration(rand.Float64() * j * float64(d))
+	}
+	if b.MaxElapsed > 0 && elapsed+d > b.MaxElapsed {
+		return 0, false
+	}
+	return d, true
+}
+
+// ConstantBackoff waits the same delay between attempts, forever.
+type ConstantBackoff time.Duration
+
+func (b ConstantBackoff) Next(int, time.Duration) (time.Duration, bool) {
+	return time.Duration(b), true
+}
+
+type WsState string
+
+const (
+	// WS_STATE_CONNECTING is sent before dialing, Attempt is 0 for Start
+	WS_STATE_CONNECTING WsState = "connecting"
+	// WS_STATE_CONNECTED is sent once the websocket handshake succeeded
+	WS_STATE_CONNECTED WsState = "connected"
+	// WS_STATE_WELCOMED is sent when the WELCOME message arrived, the connection is usable
+	WS_STATE_WELCOMED WsState = "welcomed"
+	// WS_STATE_RESUBSCRIBED is sent after all subscriptions were sent again on a new connection
+	WS_STATE_RESUBSCRIBED WsState = "resubscribed"
+	// WS_STATE_DISCONNECTED is sent when a connection or connection attempt is lost, Err is the reason
+	WS_STATE_DISCONNECTED WsState = "disconnected"
+	// WS_STATE_GAVE_UP is sent when the backoff gave up reconnecting, the client is stopped
+	WS_STATE_GAVE_UP WsState = "gave_up"
+)
+
+var (
+	// ErrWsGaveUp is returned by Err after the backoff gave up reconnecting.
+	ErrWsGaveUp = errors.New("birdeye: gave up reconnecting to websocket")
+	// ErrWsStale is the disconnect reason when a stream was stale, see WithWsStaleTimeout.
+	ErrWsStale = errors.New("birdeye: websocket stream is stale")
+)
+
+// WsStateEvent reports a change of the connection state.
+type WsStateEvent struct {
+	State WsState
+	// Attempt is the reconnect attempt, starting at 1, 0 for Start
+	Attempt int
+	// Err is why the connection was lost or the attempt failed
+	Err  error
+	Time time.Time
+}
+
+// WithWsBackoff sets the reconnect backoff, default DefaultWsBackoff.
+func WithWsBackoff(b WsBackoff) WsOption {
+	return func(c *WsClient) {
+		c.backoff = b
+	}
+}
+
+// WithWsStateHandler calls fn on every connection state change, in order.
+// fn is called from the client's goroutines, it must not block and must not call Close.
+func WithWsStateHandler(fn func(WsStateEvent)) WsOption {
+	return func(c *WsClient) {
+		c.onState = fn
+	}
+}
+
+func (c *WsClient) emitState(state WsState, attempt int, err error) {
+	if c.onState == nil {
+		return
+	}
+	c.muState.Lock()
+	defer c.muState.Unlock()
+	c.onState(WsStateEvent{State: state, Attempt: attempt, Err: err, Time: time.Now()})
+}
@@ -0,0 +1,33 @@
+package gobe_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dwdwow/gobe"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := gobe.ExponentialBackoff{BaseDelay: time.Second, MaxDelay: 5 * time.Second, MaxAttempts: 6}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		d, ok := b.Next(i+1, 0)
+		if !ok || d != e {
+			t.Fatalf("attempt %d: expected %s, got %s %v", i+1, e, d, ok)
+		}
+	}
+	if _, ok := b.Next(6, 0); ok {
+		t.Fatal("expected to give up after max attempts")
+	}
+
+	b = gobe.ExponentialBackoff{BaseDelay: time.Second, MaxElapsed: 10 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d, ok := b.Next(2, 0)
+		if !ok || d < time.Second || d > 2*time.Second {
+			t.Fatalf("delay %s out of jitter range", d)
+		}
+	}
+	if _, ok := b.Next(1, 10*time.Second); ok {
+		t.Fatal("expected to give up after max elapsed")
+	}
+}
@@ -0,0 +1,235 @@
+package gobe
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// REPLAY_MAX_SPEED replays frames without waiting between them.
+const REPLAY_MAX_SPEED = 0
+
+// wsRecord is a line of a recording.
+type wsRecord struct {
+	// Time is when the frame was received
+	Time time.Time `json:"time"`
+	// Frame is the frame if it is valid json, as birdeye frames are
+	Frame json.RawMessage `json:"frame,omitempty"`
+	// Text is the frame if it is not valid json
+	Text string `json:"text,omitempty"`
+}
+
+// WsRecorder appends the text frames received by a WsClient to a NDJSON recording,
+// one {"time": ..., "frame": ...} object per line. Replay it with WsClient.Replay.
+type WsRecorder struct {
+	mu     sync.Mutex
+	w      *bufio.Writer
+	closer []io.Closer
+	err    error
+}
+
+// NewWsRecorder records to w, Close flushes the recording but does not close w.
+func NewWsRecorder(w io.Writer) *WsRecorder {
+	return &WsRecorder{w: bufio.NewWriter(w)}
+}
+
+// CreateWsRecording creates the recording file path, gzip compressed if path ends with ".gz".
+func CreateWsRecording(path string) (*WsRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("birdeye: create recording: %w", err)
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		r := NewWsRecorder(f)
+		r.closer = []io.Closer{f}
+		return r, nil
+	}
+	zw := gzip.NewWriter(f)
+	r := NewWsRecorder(zw)
+	// the gzip writer must be closed before the file
+	r.closer = []io.Closer{zw, f}
+	return r, nil
+}
+
+// Record appends a frame received at t, the first write error is returned by every following call.
+func (r *WsRecorder) Record(t time.Time, frame []byte) error {
+	rec := wsRecord{Time: t}
+	if json.Valid(frame) {
+		rec.Frame = frame
+	} else {
+		rec.Text = string(frame)
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("birdeye: marshal record: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	b = append(b, '\n')
+	if _, err := r.w.Write(b); err != nil {
+		r.err = fmt.Errorf("birdeye: write record: %w", err)
+	}
+	return r.err
+}
+
+// Flush writes the buffered records to the underlying writer.
+func (r *WsRecorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.w.Flush(); err != nil {
+		r.err = fmt.Errorf("birdeye: flush recording: %w", err)
+	}
+	return r.err
+}
+
+// Close flushes the recording and closes the file created by CreateWsRecording.
+func (r *WsRecorder) Close() error {
+	err := r.Flush()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := []error{err}
+	for _, c := range r.closer {
+		errs = append(errs, c.Close())
+	}
+	r.closer = nil
+	return errors.Join(errs...)
+}
+
+// WithWsRecorder records every text frame received by the client before it is handled.
+// Record errors are logged once, the client keeps running.
+func WithWsRecorder(r *WsRecorder) WsOption {
+	return func(c *WsClient) {
+		c.recorder = r
+	}
+}
+
+// record records a received frame, called by the read loop.
+func (c *WsClient) record(b []byte) {
+	if err := c.recorder.Record(time.Now(), b); err != nil && c.recordFailed.CompareAndSwap(false, true) {
+		c.logger.Error("birdeye: failed to record frame, recording stopped", "error", err)
+	}
+}
+
+// NewWsReplayClient creates a client which never connects, its messages come from Replay.
+// Subscriptions are not sent anywhere but filter the replayed messages like on a live client.
+func NewWsReplayClient(chain string, logger *slog.Logger, opts ...WsOption) *WsClient {
+	c := newWsClient(chain, "", logger, opts...)
+	c.replay = true
+	return c
+}
+
+// Replay feeds the frames of a recording through the message handling of a client created by NewWsReplayClient.
+// speed 1 replays in real time, 10 ten times faster and REPLAY_MAX_SPEED without waiting.
+// It returns at the end of the recording, when ctx is done or the client is closed.
+func (c *WsClient) Replay(ctx context.Context, r io.Reader, speed float64) error {
+	if !c.replay {
+		return errors.New("birdeye: replay needs a client created by NewWsReplayClient")
+	}
+	if speed < 0 {
+		return fmt.Errorf("birdeye: invalid replay speed %v", speed)
+	}
+	c.muReplay.Lock()
+	defer c.muReplay.Unlock()
+	dec := json.NewDecoder(r)
+	var first time.Time
+	start := time.Now()
+	for {
+		var rec wsRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("birdeye: decode record: %w", err)
+		}
+		if first.IsZero() {
+			first = rec.Time
+		}
+		at := start
+		if speed != REPLAY_MAX_SPEED {
+			at = start.Add(time.Duration(float64(rec.Time.Sub(first)) / speed))
+		}
+		if err := c.sleepUntil(ctx, at); err != nil {
+			return err
+		}
+		frame := []byte(rec.Frame)
+		if rec.Frame == nil {
+			frame = []byte(rec.Text)
+		}
+		if err := c.handleReplayed(frame); err != nil {
+			return err
+		}
+	}
+}
+
+// handleReplayed handles a frame like the read loop, Close waits for it before closing the channels.
+func (c *WsClient) handleReplayed(frame []byte) error {
+	c.muRW.Lock()
+	if c.ctx.Err() != nil {
+		c.muRW.Unlock()
+		return ErrWsClosed
+	}
+	c.wg.Add(1)
+	c.muRW.Unlock()
+	defer c.wg.Done()
+	c.touch()
+	c.msgHandler(frame)
+	return nil
+}
+
+// ReplayFile replays the recording file path, gzip compressed if path ends with ".gz", see Replay.
+func (c *WsClient) ReplayFile(ctx context.Context, path string, speed float64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("birdeye: open recording: %w", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("birdeye: open recording: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return c.Replay(ctx, r, speed)
+}
+
+// sleepUntil waits until t, returning early when ctx is done or the client is closed.
+func (c *WsClient) sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.ctx.Err() != nil {
+			return ErrWsClosed
+		}
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.ctx.Done():
+		return ErrWsClosed
+	}
+}
@@ -0,0 +1,240 @@
+package gobe
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithWsRecovery backfills the messages missed while reconnecting using rest,
+// which needs an api key with access to the OHLCV and trades endpoints.
+//
+// After resubscribing, simple price, base quote price and txs subscriptions created by Subscribe
+// fetch the candles or trades since their last message and deliver them on the same channel,
+// with Backfilled set. Recovered messages may interleave with live ones:
+// trades already received live are skipped, and so are candles whose bucket
+// was received live after reconnecting. The candle of the last message before the
+// disconnection is delivered again with its final values.
+// Complex subscriptions are not recovered, nor are txs subscriptions of a pair address:
+// txs messages carry no pair address, so the last message of the subscription is unknown.
+func WithWsRecovery(rest *Client) WsOption {
+	return func(c *WsClient) {
+		c.rest = rest
+	}
+}
+
+// wsRecovery backfills the messages of one subscription.
+type wsRecovery struct {
+	// timeOf returns the UnixTime or BlockUnixTime of a message
+	timeOf func(d any) int64
+	// idOf identifies a message for de-duplication
+	idOf func(d any) string
+	// perBucket is set for candles, which are updated live until their bucket closes
+	perBucket bool
+	// fetch calls emit with every message, flagged as backfilled, from <= time <= to
+	fetch func(ctx context.Context, rest *Client, chain string, from, to int64, emit func(d any)) error
+}
+
+// wsRecoveryOf returns how to backfill the messages of req, nil if they can not be.
+func wsRecoveryOf(req WsSubRequest) *wsRecovery {
+	switch r := req.(type) {
+	case WsSubData[WsPriceSubData]:
+		d := r.Data
+		if d.QueryType == QUERY_TYPE_COMPLEX || d.Address == "" || !d.ChartType.Valid() {
+			return nil
+		}
+		ohlcv := (*Client).OHLCVByTokenRange
+		if d.Currency == WS_CURRENCY_PAIR {
+			ohlcv = (*Client).OHLCVByPairRange
+		}
+		return &wsRecovery{
+			timeOf:    func(v any) int64 { return v.(*WsPriceData).UnixTime },
+			idOf:      func(v any) string { return strconv.FormatInt(v.(*WsPriceData).UnixTime, 10) },
+			perBucket: true,
+			fetch: func(ctx context.Context, rest *Client, chain string, from, to int64, emit func(any)) error {
+				res, err := ohlcv(rest, ctx, chain, d.Address, d.ChartType, from, to, nil)
+				if err != nil {
+					return err
+				}
+				for _, item := range res.Items {
+					emit(&WsPriceData{
+						O: item.O, H: item.H, L: item.L, C: item.C, V: item.V,
+						EventType: "ohlcv", Type: item.Type, UnixTime: item.UnixTime, Address: d.Address,
+						Backfilled: true,
+					})
+				}
+				return nil
+			},
+		}
+	case WsSubData[WsBaseQuotePriceSubData]:
+		d := r.Data
+		if !d.ChartType.Valid() {
+			return nil
+		}
+		return &wsRecovery{
+			timeOf:    func(v any) int64 { return v.(*WsBaseQuotePriceData).UnixTime },
+			idOf:      func(v any) string { return strconv.FormatInt(v.(*WsBaseQuotePriceData).UnixTime, 10) },
+			perBucket: true,
+			fetch: func(ctx context.Context, rest *Client, chain string, from, to int64, emit func(any)) error {
+				res, err := rest.OHLCVByBaseQuoteRange(ctx, chain, d.BaseAddress, d.QuoteAddress, d.ChartType, from, to, nil)
+				if err != nil {
+					return err
+				}
+				for _, item := range res.Items {
+					emit(&WsBaseQuotePriceData{
+						O: item.O, H: item.H, L: item.L, C: item.C, V: item.VBase,
+						EventType: "ohlcv", Type: string(d.ChartType), UnixTime: item.UnixTime,
+						BaseAddress: d.BaseAddress, QuoteAddress: d.QuoteAddress,
+						Backfilled: true,
+					})
+				}
+				return nil
+			},
+		}
+	case WsSubData[WsTxsSubData]:
+		d := r.Data
+		if d.QueryType == QUERY_TYPE_COMPLEX || d.Address == "" {
+			return nil
+		}
+		txType := d.TxsType
+		if txType == "" {
+			txType = TX_TYPE_SWAP
+		}
+		return &wsRecovery{
+			timeOf: func(v any) int64 { return v.(*WsTxsData).BlockUnixTime },
+			idOf:   func(v any) string { return v.(*WsTxsData).TxHash },
+			fetch: func(ctx context.Context, rest *Client, chain string, from, to int64, emit func(any)) error {
+				return rest.BackfillTrades(ctx, chain, d.Address, txType, from, to, func(item RespTradesByTokenItem) error {
+					emit(&WsTxsData{
+						BlockUnixTime: item.BlockUnixTime, Owner: item.Owner, Source: item.Source, TxHash: item.TxHash, Alias: item.Alias,
+						From: wsTxTokenInfoOf(item.From), To: wsTxTokenInfoOf(item.To),
+						Backfilled: true,
+					})
+					return nil
+				})
+			},
+		}
+	}
+	return nil
+}
+
+func wsTxTokenInfoOf(t RespTradesByTokenTokenInfo) WsTxTokenInfo {
+	info := WsTxTokenInfo{
+		Symbol: t.Symbol, Decimals: int(t.Decimals), Address: t.Address,
+		Amount: t.Amount, UiAmount: t.UiAmount, NearestPrice: t.NearestPrice,
+		ChangeAmount: t.ChangeAmount, UiChangeAmount: t.UiChangeAmount,
+	}
+	if t.Price != nil {
+		info.Price = *t.Price
+	}
+	return info
+}
+
+// wsRecoverSeenMax bounds the ids remembered for de-duplication.
+const wsRecoverSeenMax = 10000
+
+// recoverSuber records the last message of a subscription and backfills the gap after reconnecting.
+type recoverSuber struct {
+	wsSuber
+	match func(any) bool
+	rec   *wsRecovery
+
+	mu   sync.Mutex
+	last int64
+	// seen are the ids of live messages, since reconnecting for candles, with their time
+	seen map[string]int64
+}
+
+func newRecoverSuber(inner wsSuber, match func(any) bool, rec *wsRecovery) *recoverSuber {
+	return &recoverSuber{wsSuber: inner, match: match, rec: rec, seen: make(map[string]int64)}
+}
+
+func (s *recoverSuber) deliver(d any) {
+	if s.match == nil || s.match(d) {
+		s.mu.Lock()
+		s.remember(d)
+		s.mu.Unlock()
+	}
+	s.wsSuber.deliver(d)
+}
+
+// remember records a delivered message, mu must be held.
+func (s *recoverSuber) remember(d any) {
+	t := s.rec.timeOf(d)
+	s.last = max(s.last, t)
+	s.seen[s.rec.idOf(d)] = t
+	if len(s.seen) > wsRecoverSeenMax {
+		// forget the oldest half
+		cutoff := s.last - (s.last-s.oldest())/2
+		for id, t := range s.seen {
+			if t < cutoff {
+				delete(s.seen, id)
+			}
+		}
+	}
+}
+
+func (s *recoverSuber) oldest() int64 {
+	oldest := s.last
+	for _, t := range s.seen {
+		oldest = min(oldest, t)
+	}
+	return oldest
+}
+
+// recover backfills from the last message, or lost if there was none, until now.
+func (s *recoverSuber) recover(ctx context.Context, rest *Client, chain string, lost time.Time) error {
+	s.mu.Lock()
+	from := s.last
+	if from == 0 {
+		from = lost.Unix()
+	}
+	if s.rec.perBucket {
+		// the last candle is sent again with its final values, later ones only if not received live
+		clear(s.seen)
+	}
+	s.mu.Unlock()
+	to := time.Now().Unix()
+	if to <= from {
+		return nil
+	}
+	return s.rec.fetch(ctx, rest, chain, from, to, func(d any) {
+		s.mu.Lock()
+		id := s.rec.idOf(d)
+		_, seen := s.seen[id]
+		if !seen {
+			s.remember(d)
+		}
+		s.mu.Unlock()
+		if !seen {
+			s.wsSuber.deliver(d)
+		}
+	})
+}
+
+// recoverGaps backfills the subscriptions of recoverSubers in the background after reconnecting.
+func (c *WsClient) recoverGaps(lost time.Time) {
+	if c.rest == nil {
+		return
+	}
+	var subers []*recoverSuber
+	c.muSubers.RLock()
+	for _, ss := range c.subers {
+		for _, s := range ss {
+			if rs, ok := s.(*recoverSuber); ok {
+				subers = append(subers, rs)
+			}
+		}
+	}
+	c.muSubers.RUnlock()
+	for _, s := range subers {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if err := s.recover(c.ctx, c.rest, c.chain, lost); err != nil && c.ctx.Err() == nil {
+				c.logger.Error("birdeye: failed to recover missed messages", "type", s.dataType(), "error", err)
+			}
+		}()
+	}
+}
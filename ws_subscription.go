@@ -0,0 +1,199 @@
+package gobe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Subscription is a typed stream of the messages produced by one subscription request.
+type Subscription[T any] struct {
+	// C receives the messages of the subscription
+	C <-chan T
+
+	c     *WsClient
+	req   WsSubRequest
+	suber *chanSuber[T]
+	// reg is the suber registered on the client, suber or its recovering wrapper
+	reg   wsSuber
+	entry *wsSubEntry
+	once  sync.Once
+}
+
+// Unsubscribe stops the subscription and closes C.
+// The UNSUBSCRIBE_* message is sent when no other subscription uses the same request.
+// Calling Unsubscribe more than once is a no-op.
+func (s *Subscription[T]) Unsubscribe() error {
+	var err error
+	s.once.Do(func() {
+		s.suber.close()
+		s.c.removeSuber(s.reg)
+		err = s.c.WsUnsub(s.req)
+	})
+	return err
+}
+
+// Dropped returns how many messages were dropped because the buffer of the subscription was full.
+func (s *Subscription[T]) Dropped() uint64 {
+	return s.suber.dropped.Load()
+}
+
+// Request returns the subscription message sent to birdeye.
+func (s *Subscription[T]) Request() WsSubRequest {
+	return s.req
+}
+
+// Subscribe sends the subscription request and returns the typed stream of its messages.
+// T must be the data struct of the request type, e.g. WsPriceData for SUBSCRIBE_PRICE.
+//
+// Simple price, base quote price and token txs subscriptions only receive the messages
+// of their address, other subscriptions receive every message of their data type on the connection.
+//
+// opts override the buffer and overflow policy set by WithWsDispatch.
+// Messages missed while reconnecting are backfilled if the client was created with WithWsRecovery.
+func Subscribe[T any](ctx context.Context, c *WsClient, req WsSubRequest, opts ...WsSubOption) (*Subscription[T], error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	t, req, err := checkSubRequest[T](req)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := newSubConfig(c.dispatch, t, opts)
+	if err != nil {
+		return nil, err
+	}
+	suber := newChanSuber(c.suberEnv(), t, cfg, wsSubMatcher(req), func(d any) (T, bool) {
+		v, ok := d.(*T)
+		if !ok {
+			return *new(T), false
+		}
+		return *v, true
+	})
+	var reg wsSuber = suber
+	if c.rest != nil {
+		if rec := wsRecoveryOf(req); rec != nil {
+			reg = newRecoverSuber(suber, wsSubMatcher(req), rec)
+		}
+	}
+	c.addSuber(reg)
+	entry, err := c.wsSub(req)
+	if err != nil {
+		suber.close()
+		c.removeSuber(reg)
+		return nil, err
+	}
+	sub := &Subscription[T]{C: suber.ch, c: c, req: req, suber: suber, reg: reg, entry: entry}
+	if cfg.ack {
+		if err := sub.Wait(ctx); err != nil {
+			sub.Unsubscribe()
+			return nil, err
+		}
+	}
+	return sub, nil
+}
+
+// checkSubRequest returns the data type of req, which must be T, and req with defaults set.
+func checkSubRequest[T any](req WsSubRequest) (WsDataType, WsSubRequest, error) {
+	t, ok := wsSubDataTypes[req.SubType()]
+	if !ok {
+		return "", nil, fmt.Errorf("birdeye: unsupported subscription type %q", req.SubType())
+	}
+	if _, ok := wsDataTypes[t]().(*T); !ok {
+		return "", nil, fmt.Errorf("birdeye: %s produces %s messages, not %T", req.SubType(), t, *new(T))
+	}
+	if d, ok := req.(WsLargeTradeTxsSubData); ok && d.Type == "" {
+		d.Type = SUBSCRIBE_LARGE_TRADE_TXS
+		req = d
+	}
+	return t, req, nil
+}
+
+// wsSubMatcher returns a filter for the messages of a simple subscription, nil if it can not be filtered.
+func wsSubMatcher(req WsSubRequest) func(any) bool {
+	switch r := req.(type) {
+	case WsSubData[WsPriceSubData]:
+		if r.Data.QueryType == QUERY_TYPE_COMPLEX || r.Data.Address == "" {
+			return nil
+		}
+		return func(d any) bool {
+			p, ok := d.(*WsPriceData)
+			return ok && p.Address == r.Data.Address && (r.Data.ChartType == "" || p.Type == r.Data.ChartType)
+		}
+	case WsSubData[WsBaseQuotePriceSubData]:
+		return func(d any) bool {
+			p, ok := d.(*WsBaseQuotePriceData)
+			return ok && p.BaseAddress == r.Data.BaseAddress && p.QuoteAddress == r.Data.QuoteAddress &&
+				(r.Data.ChartType == "" || p.Type == string(r.Data.ChartType))
+		}
+	case WsSubData[WsTxsSubData]:
+		// txs messages carry token addresses but no pair address
+		if r.Data.QueryType == QUERY_TYPE_COMPLEX || r.Data.Address == "" {
+			return nil
+		}
+		return func(d any) bool {
+			tx, ok := d.(*WsTxsData)
+			return ok && (tx.From.Address == r.Data.Address || tx.To.Address == r.Data.Address)
+		}
+	}
+	return nil
+}
+
+// SubscribePrice subscribes to the candles of a token or pair.
+func (c *WsClient) SubscribePrice(ctx context.Context, d WsPriceSubData, opts ...WsSubOption) (*Subscription[WsPriceData], error) {
+	if d.QueryType == "" {
+		d.QueryType = QUERY_TYPE_SIMPLE
+	}
+	return Subscribe[WsPriceData](ctx, c, WsSubData[WsPriceSubData]{Type: SUBSCRIBE_PRICE, Data: d}, opts...)
+}
+
+// SubscribePriceComplex subscribes to the candles of a complex query, see ComplexSub and ValidateQuery.
+func (c *WsClient) SubscribePriceComplex(ctx context.Context, d WsComplexSubData, opts ...WsSubOption) (*Subscription[WsPriceData], error) {
+	if err := validateComplexQuery(SUBSCRIBE_PRICE, d.Query); err != nil {
+		return nil, err
+	}
+	d.QueryType = QUERY_TYPE_COMPLEX
+	return Subscribe[WsPriceData](ctx, c, WsSubData[WsComplexSubData]{Type: SUBSCRIBE_PRICE, Data: d}, opts...)
+}
+
+// SubscribeTxs subscribes to the trades of a token or pair.
+func (c *WsClient) SubscribeTxs(ctx context.Context, d WsTxsSubData, opts ...WsSubOption) (*Subscription[WsTxsData], error) {
+	if d.QueryType == "" {
+		d.QueryType = QUERY_TYPE_SIMPLE
+	}
+	return Subscribe[WsTxsData](ctx, c, WsSubData[WsTxsSubData]{Type: SUBSCRIBE_TXS, Data: d}, opts...)
+}
+
+// SubscribeTxsComplex subscribes to the trades of a complex query, see ComplexSub and ValidateQuery.
+func (c *WsClient) SubscribeTxsComplex(ctx context.Context, d WsComplexSubData, opts ...WsSubOption) (*Subscription[WsTxsData], error) {
+	if err := validateComplexQuery(SUBSCRIBE_TXS, d.Query); err != nil {
+		return nil, err
+	}
+	d.QueryType = QUERY_TYPE_COMPLEX
+	return Subscribe[WsTxsData](ctx, c, WsSubData[WsComplexSubData]{Type: SUBSCRIBE_TXS, Data: d}, opts...)
+}
+
+// SubscribeBaseQuotePrice subscribes to the candles of a base and quote token.
+func (c *WsClient) SubscribeBaseQuotePrice(ctx context.Context, d WsBaseQuotePriceSubData, opts ...WsSubOption) (*Subscription[WsBaseQuotePriceData], error) {
+	return Subscribe[WsBaseQuotePriceData](ctx, c, WsSubData[WsBaseQuotePriceSubData]{Type: SUBSCRIBE_BASE_QUOTE_PRICE, Data: d}, opts...)
+}
+
+// SubscribeTokenNewListing subscribes to new token listings.
+func (c *WsClient) SubscribeTokenNewListing(ctx context.Context, d WsTokenNewListingSubData, opts ...WsSubOption) (*Subscription[WsTokenNewListingData], error) {
+	return Subscribe[WsTokenNewListingData](ctx, c, WsSubData[WsTokenNewListingSubData]{Type: SUBSCRIBE_TOKEN_NEW_LISTING, Data: d}, opts...)
+}
+
+// SubscribeNewPair subscribes to new pairs.
+func (c *WsClient) SubscribeNewPair(ctx context.Context, d WsNewPairSubData, opts ...WsSubOption) (*Subscription[WsNewPairData], error) {
+	return Subscribe[WsNewPairData](ctx, c, WsSubData[WsNewPairSubData]{Type: SUBSCRIBE_NEW_PAIR, Data: d}, opts...)
+}
+
+// SubscribeLargeTradeTxs subscribes to large trades of every token.
+func (c *WsClient) SubscribeLargeTradeTxs(ctx context.Context, d WsLargeTradeTxsSubData, opts ...WsSubOption) (*Subscription[WsLargeTradeTxsData], error) {
+	return Subscribe[WsLargeTradeTxsData](ctx, c, d, opts...)
+}
+
+// SubscribeWalletTxs subscribes to the transactions of a wallet.
+func (c *WsClient) SubscribeWalletTxs(ctx context.Context, d WsWalletTxsSubData, opts ...WsSubOption) (*Subscription[WsWalletTxsData], error) {
+	return Subscribe[WsWalletTxsData](ctx, c, WsSubData[WsWalletTxsSubData]{Type: SUBSCRIBE_WALLET_TXS, Data: d}, opts...)
+}